@@ -4,8 +4,28 @@ import (
 	"context"
 	"strings"
 	"time"
+
+	"github.com/steven-zou/go-plugin/pkg/logger"
 )
 
+//LabelKey is the key of the label value in the plugin context,
+//which tells the plugin what kind of request is incoming
+const LabelKey = "label"
+
+//ExecutionIDKey is the key of the execution ID in the plugin context.
+//It's set by the plugin manager when executing the plugin with 'Execute'.
+const ExecutionIDKey = "execution_id"
+
+//TraceIDKey is the key of the trace ID in the plugin context.
+//It's set by the plugin manager when executing the plugin with tracing
+//and can be set by the caller to join the plugin spans to its trace.
+const TraceIDKey = "trace_id"
+
+//SpanIDKey is the key of the ID of the current span in the plugin context.
+//It's set by the plugin manager to the span of the plugin execution, and
+//can be set by the caller as the parent span of the plugin execution.
+const SpanIDKey = "span_id"
+
 //PluginContext help to provide related information/parameters to the
 //plugin execution entry method.
 //PluginContext inherits all from the context.Context
@@ -23,6 +43,18 @@ type ValueContext interface {
 	SetValue(key string, value interface{})
 }
 
+//ValueLister is implemented by the plugin contexts which can list all their values
+type ValueLister interface {
+	//Get all the values
+	Values() map[string]interface{}
+}
+
+//ValueDeleter is implemented by the plugin contexts which can delete their values
+type ValueDeleter interface {
+	//Delete the value set by the key
+	DeleteValue(key string)
+}
+
 //BasePluginContext implemented as default plugin context
 type BasePluginContext struct {
 	//For compatible with system context
@@ -30,11 +62,22 @@ type BasePluginContext struct {
 
 	//For keeping values
 	valueMap map[string]interface{}
+
+	//For looking up the values not set in this context
+	parent ValueContext
 }
 
 //GetValue implements 'GetValue' in ValueContext interface
 func (bpc *BasePluginContext) GetValue(key string) interface{} {
-	return bpc.valueMap[key]
+	if v, ok := bpc.valueMap[key]; ok {
+		return v
+	}
+
+	if bpc.parent != nil {
+		return bpc.parent.GetValue(key)
+	}
+
+	return nil
 }
 
 //SetValue implements 'SetValue' in ValueContext interface
@@ -45,6 +88,30 @@ func (bpc *BasePluginContext) SetValue(key string, value interface{}) {
 	}
 }
 
+//Values implements 'Values' in ValueLister interface.
+//The values of the parent are also included if the parent can list them.
+func (bpc *BasePluginContext) Values() map[string]interface{} {
+	values := make(map[string]interface{}, len(bpc.valueMap))
+	if lister, ok := bpc.parent.(ValueLister); ok {
+		for k, v := range lister.Values() {
+			values[k] = v
+		}
+	}
+
+	for k, v := range bpc.valueMap {
+		values[k] = v
+	}
+
+	return values
+}
+
+//DeleteValue implements 'DeleteValue' in ValueDeleter interface.
+//Only the value set to this context is deleted, the value of the parent
+//with the same key is visible again.
+func (bpc *BasePluginContext) DeleteValue(key string) {
+	delete(bpc.valueMap, key)
+}
+
 //Deadline implements 'Deadline' in context.Context
 func (bpc *BasePluginContext) Deadline() (deadline time.Time, ok bool) {
 	return bpc.basedOnContext.Deadline()
@@ -72,3 +139,117 @@ func Background() PluginContext {
 		valueMap:       make(map[string]interface{}),
 	}
 }
+
+//New builds the plugin context based on the context with the values.
+//The plugin context is done when the context is done.
+func New(ctx context.Context, values map[string]interface{}) PluginContext {
+	pc := &BasePluginContext{
+		basedOnContext: ctx,
+		valueMap:       make(map[string]interface{}, len(values)),
+	}
+	for k, v := range values {
+		pc.SetValue(k, v)
+	}
+
+	return pc
+}
+
+//Values returns all the values of the plugin context.
+//If the context is not able to list its values, nil is returned.
+func Values(ctx ValueContext) map[string]interface{} {
+	if lister, ok := ctx.(ValueLister); ok {
+		return lister.Values()
+	}
+
+	return nil
+}
+
+//DeleteValue deletes the value of the key from the plugin context.
+//Unlike setting the nil value, the key is not listed in the values after deleting.
+//If the context is not able to delete its values, the value is set to nil.
+func DeleteValue(ctx ValueContext, key string) {
+	if deleter, ok := ctx.(ValueDeleter); ok {
+		deleter.DeleteValue(key)
+		return
+	}
+
+	ctx.SetValue(key, nil)
+}
+
+//WithCancel returns a derived plugin context with a new Done channel.
+//The values of the parent are visible to the derived context,
+//the values set to the derived context are not visible to the parent.
+func WithCancel(parent PluginContext) (PluginContext, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+
+	return derive(ctx, parent), cancel
+}
+
+//WithTimeout returns a derived plugin context which is canceled after the timeout.
+//The values of the parent are visible to the derived context,
+//the values set to the derived context are not visible to the parent.
+func WithTimeout(parent PluginContext, timeout time.Duration) (PluginContext, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(parent, timeout)
+
+	return derive(ctx, parent), cancel
+}
+
+func derive(ctx context.Context, parent ValueContext) PluginContext {
+	return &BasePluginContext{
+		basedOnContext: ctx,
+		valueMap:       make(map[string]interface{}),
+		parent:         parent,
+	}
+}
+
+//loggerKey is the context key of the plugin logger
+type loggerKey struct{}
+
+//loggerContext attaches the logger to the plugin context.
+//The values are read from and written to the parent directly.
+type loggerContext struct {
+	PluginContext
+
+	//The attached logger
+	logger logger.Logger
+}
+
+//Value implements 'Value' in context.Context
+func (lc *loggerContext) Value(key interface{}) interface{} {
+	if _, ok := key.(loggerKey); ok {
+		return lc.logger
+	}
+
+	return lc.PluginContext.Value(key)
+}
+
+//Values implements 'Values' in ValueLister interface
+func (lc *loggerContext) Values() map[string]interface{} {
+	return Values(lc.PluginContext)
+}
+
+//DeleteValue implements 'DeleteValue' in ValueDeleter interface
+func (lc *loggerContext) DeleteValue(key string) {
+	DeleteValue(lc.PluginContext, key)
+}
+
+//WithLogger returns the plugin context with the logger attached.
+//Unlike the derived contexts, the values set to the returned context
+//are set to the parent.
+func WithLogger(parent PluginContext, l logger.Logger) PluginContext {
+	return &loggerContext{
+		PluginContext: parent,
+		logger:        l,
+	}
+}
+
+//Logger returns the logger attached to the context.
+//The plugin manager attaches the logger tagged with the plugin name and version
+//when calling the plugin. If no logger is attached, logger.Discard is returned.
+func Logger(ctx context.Context) logger.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(logger.Logger); ok {
+		return l
+	}
+
+	return logger.Discard
+}
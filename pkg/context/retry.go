@@ -0,0 +1,39 @@
+package context
+
+import "errors"
+
+//AttemptKey is the key of the attempt number (starting from 1) in the plugin context.
+//It's set by the plugin manager when executing the plugin with the retry policy.
+const AttemptKey = "attempt"
+
+//nonRetryableError marks the error which should not be retried
+type nonRetryableError struct {
+	err error
+}
+
+//Error implements the error interface
+func (ne *nonRetryableError) Error() string {
+	return ne.err.Error()
+}
+
+//Unwrap returns the marked error
+func (ne *nonRetryableError) Unwrap() error {
+	return ne.err
+}
+
+//NonRetryable marks the error returned by the plugin as non-retryable, the plugin
+//manager does not retry the execution even if the retry policy allows.
+//Nil is returned if the error is nil.
+func NonRetryable(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &nonRetryableError{err: err}
+}
+
+//IsNonRetryable checks if the error is marked by NonRetryable
+func IsNonRetryable(err error) bool {
+	var ne *nonRetryableError
+	return errors.As(err, &ne)
+}
@@ -0,0 +1,166 @@
+//Package logger defines the leveled logger with key/value fields used by
+//the plugin manager and the plugins.
+package logger
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+//Level is the severity of the log entry
+type Level int
+
+//The log levels
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+//String returns the upper case name of the level
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	default:
+		return fmt.Sprintf("LEVEL(%d)", int(l))
+	}
+}
+
+//Logger writes the leveled log entries with the message and the key/value fields.
+//The fields are given as the alternating keys and values,
+//e.g: l.Info("plugin loaded", "plugin", "sample", "version", "1.0.0")
+type Logger interface {
+	//Log the entry at debug level
+	Debug(msg string, keysAndValues ...interface{})
+
+	//Log the entry at info level
+	Info(msg string, keysAndValues ...interface{})
+
+	//Log the entry at warn level
+	Warn(msg string, keysAndValues ...interface{})
+
+	//Log the entry at error level
+	Error(msg string, keysAndValues ...interface{})
+
+	//Create a child logger adding the key/value fields to all its entries
+	With(keysAndValues ...interface{}) Logger
+}
+
+//StdLogger is the Logger writing to the standard log.Logger with the
+//'[LEVEL]: message key=value' format
+type StdLogger struct {
+	//The underlying logger
+	out *log.Logger
+
+	//The min level to write
+	level Level
+
+	//The fields added to all the entries
+	fields []interface{}
+}
+
+//NewStdLogger is constructor of StdLogger.
+//The entries lower than the level are dropped.
+//If out is nil, the default logger of the 'log' package is used.
+func NewStdLogger(out *log.Logger, level Level) *StdLogger {
+	if out == nil {
+		out = log.Default()
+	}
+
+	return &StdLogger{
+		out:   out,
+		level: level,
+	}
+}
+
+//Debug implements the Logger interface
+func (sl *StdLogger) Debug(msg string, keysAndValues ...interface{}) {
+	sl.log(LevelDebug, msg, keysAndValues)
+}
+
+//Info implements the Logger interface
+func (sl *StdLogger) Info(msg string, keysAndValues ...interface{}) {
+	sl.log(LevelInfo, msg, keysAndValues)
+}
+
+//Warn implements the Logger interface
+func (sl *StdLogger) Warn(msg string, keysAndValues ...interface{}) {
+	sl.log(LevelWarn, msg, keysAndValues)
+}
+
+//Error implements the Logger interface
+func (sl *StdLogger) Error(msg string, keysAndValues ...interface{}) {
+	sl.log(LevelError, msg, keysAndValues)
+}
+
+//With implements the Logger interface
+func (sl *StdLogger) With(keysAndValues ...interface{}) Logger {
+	fields := make([]interface{}, 0, len(sl.fields)+len(keysAndValues))
+	fields = append(fields, sl.fields...)
+	fields = append(fields, keysAndValues...)
+
+	return &StdLogger{
+		out:    sl.out,
+		level:  sl.level,
+		fields: fields,
+	}
+}
+
+func (sl *StdLogger) log(level Level, msg string, keysAndValues []interface{}) {
+	if level < sl.level {
+		return
+	}
+
+	b := new(strings.Builder)
+	fmt.Fprintf(b, "[%s]: %s", level, msg)
+	writeFields(b, sl.fields)
+	writeFields(b, keysAndValues)
+
+	sl.out.Output(3, b.String())
+}
+
+//writeFields writes the key/value fields as ' key=value',
+//the value is quoted if it has spaces, quotes or '='.
+//The key without a value gets the '!MISSING' value.
+func writeFields(b *strings.Builder, keysAndValues []interface{}) {
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key := fmt.Sprint(keysAndValues[i])
+		value := "!MISSING"
+		if i+1 < len(keysAndValues) {
+			value = fmt.Sprint(keysAndValues[i+1])
+		}
+
+		if strings.ContainsAny(value, " \t\n\"=") || len(value) == 0 {
+			value = fmt.Sprintf("%q", value)
+		}
+
+		fmt.Fprintf(b, " %s=%s", key, value)
+	}
+}
+
+//discard drops all the entries
+type discard struct{}
+
+func (discard) Debug(msg string, keysAndValues ...interface{}) {}
+func (discard) Info(msg string, keysAndValues ...interface{})  {}
+func (discard) Warn(msg string, keysAndValues ...interface{})  {}
+func (discard) Error(msg string, keysAndValues ...interface{}) {}
+func (d discard) With(keysAndValues ...interface{}) Logger     { return d }
+
+//Discard is the Logger dropping all the entries, use it to silence the logs
+var Discard Logger = discard{}
+
+//Default returns the default Logger, which writes the info and higher
+//level entries to the default logger of the 'log' package
+func Default() Logger {
+	return NewStdLogger(nil, LevelInfo)
+}
@@ -0,0 +1,54 @@
+//go:build go1.21
+// +build go1.21
+
+package logger
+
+import (
+	"context"
+	"log/slog"
+)
+
+//SlogLogger adapts the slog.Logger to the Logger interface
+type SlogLogger struct {
+	//The underlying logger
+	logger *slog.Logger
+}
+
+//NewSlogLogger is constructor of SlogLogger.
+//If the logger is nil, the default slog logger is used.
+func NewSlogLogger(logger *slog.Logger) *SlogLogger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &SlogLogger{
+		logger: logger,
+	}
+}
+
+//Debug implements the Logger interface
+func (sl *SlogLogger) Debug(msg string, keysAndValues ...interface{}) {
+	sl.logger.Log(context.Background(), slog.LevelDebug, msg, keysAndValues...)
+}
+
+//Info implements the Logger interface
+func (sl *SlogLogger) Info(msg string, keysAndValues ...interface{}) {
+	sl.logger.Log(context.Background(), slog.LevelInfo, msg, keysAndValues...)
+}
+
+//Warn implements the Logger interface
+func (sl *SlogLogger) Warn(msg string, keysAndValues ...interface{}) {
+	sl.logger.Log(context.Background(), slog.LevelWarn, msg, keysAndValues...)
+}
+
+//Error implements the Logger interface
+func (sl *SlogLogger) Error(msg string, keysAndValues ...interface{}) {
+	sl.logger.Log(context.Background(), slog.LevelError, msg, keysAndValues...)
+}
+
+//With implements the Logger interface
+func (sl *SlogLogger) With(keysAndValues ...interface{}) Logger {
+	return &SlogLogger{
+		logger: sl.logger.With(keysAndValues...),
+	}
+}
@@ -0,0 +1,249 @@
+package plugin
+
+import (
+	sys_context "context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/steven-zou/go-plugin/pkg/spec"
+)
+
+const (
+	//DefaultCircuitWindow is the default sliding window of counting the executions
+	DefaultCircuitWindow = time.Minute
+
+	//DefaultCircuitMinRequests is the default min executions in the window to check the error rate
+	DefaultCircuitMinRequests = 10
+
+	//DefaultCircuitOpenDuration is the default time the circuit keeps open
+	DefaultCircuitOpenDuration = 30 * time.Second
+
+	//DefaultCircuitHalfOpenRequests is the default successful trial executions to close the circuit
+	DefaultCircuitHalfOpenRequests = 1
+
+	//The count of the buckets in the sliding window
+	circuitBuckets = 10
+)
+
+//CircuitState is the state of the circuit breaker
+type CircuitState string
+
+//The states of the circuit breaker
+const (
+	//CircuitClosed lets the executions go through and counts the errors
+	CircuitClosed CircuitState = "closed"
+
+	//CircuitOpen rejects the executions until the open duration elapses
+	CircuitOpen CircuitState = "open"
+
+	//CircuitHalfOpen lets a few trial executions go through to check if the plugin recovers
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+//CircuitOpenError is returned when the execution is rejected by the open circuit
+type CircuitOpenError struct {
+	//Name of the plugin
+	Plugin string
+
+	//Version of the plugin
+	Version string
+
+	//The time left before the trial executions are allowed
+	RetryAfter time.Duration
+}
+
+//Error implements the error interface
+func (ce *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit of plugin %s:%s is open, retry after %s", ce.Plugin, ce.Version, ce.RetryAfter)
+}
+
+//circuitBucket counts the executions in a slice of the sliding window
+type circuitBucket struct {
+	start    time.Time
+	total    int
+	failures int
+}
+
+//breaker is the circuit breaker of a plugin item
+type breaker struct {
+	//The plugin with the circuit breaker
+	plugin *spec.Plugin
+
+	errorRate        float64
+	minRequests      int
+	window           time.Duration
+	openDuration     time.Duration
+	halfOpenRequests int
+
+	//Called with the new state when the state is changed
+	onChange func(state CircuitState)
+
+	//internal lock
+	lock *sync.Mutex
+
+	state    CircuitState
+	buckets  []circuitBucket
+	openedAt time.Time
+
+	//The trial executions started and succeeded in the half-open state
+	trials    int
+	successes int
+}
+
+//newBreaker creates the circuit breaker with the settings of the plugin,
+//nil is returned if the plugin has no circuit breaker
+func newBreaker(plugin *spec.Plugin, onChange func(state CircuitState)) *breaker {
+	cb := plugin.CircuitBreaker
+	if cb == nil {
+		return nil
+	}
+
+	b := &breaker{
+		plugin:           plugin,
+		errorRate:        cb.ErrorRate,
+		minRequests:      cb.MinRequests,
+		window:           DefaultCircuitWindow,
+		openDuration:     DefaultCircuitOpenDuration,
+		halfOpenRequests: cb.HalfOpenRequests,
+		onChange:         onChange,
+		lock:             new(sync.Mutex),
+		state:            CircuitClosed,
+		buckets:          make([]circuitBucket, circuitBuckets),
+	}
+	if b.minRequests == 0 {
+		b.minRequests = DefaultCircuitMinRequests
+	}
+	if b.halfOpenRequests == 0 {
+		b.halfOpenRequests = DefaultCircuitHalfOpenRequests
+	}
+	if d, err := time.ParseDuration(cb.Window); err == nil && d > 0 {
+		b.window = d
+	}
+	if d, err := time.ParseDuration(cb.OpenDuration); err == nil && d > 0 {
+		b.openDuration = d
+	}
+
+	return b
+}
+
+//allow checks if the execution can go through
+func (b *breaker) allow() error {
+	b.lock.Lock()
+	now := time.Now()
+	changed := false
+
+	if b.state == CircuitOpen {
+		if left := b.openDuration - now.Sub(b.openedAt); left > 0 {
+			b.lock.Unlock()
+			return &CircuitOpenError{Plugin: b.plugin.Name, Version: b.plugin.Version, RetryAfter: left}
+		}
+		b.state, b.trials, b.successes = CircuitHalfOpen, 0, 0
+		changed = true
+	}
+
+	if b.state == CircuitHalfOpen {
+		if b.trials >= b.halfOpenRequests {
+			b.lock.Unlock()
+			return &CircuitOpenError{Plugin: b.plugin.Name, Version: b.plugin.Version}
+		}
+		b.trials++
+	}
+	b.lock.Unlock()
+
+	if changed {
+		b.onChange(CircuitHalfOpen)
+	}
+
+	return nil
+}
+
+//record the result of the allowed execution.
+//The cancellation of the caller, the overloading and the veto of the hooks are not counted.
+func (b *breaker) record(err error) {
+	var overloaded *OverloadedError
+	var veto *HookVetoError
+	ignored := errors.Is(err, sys_context.Canceled) || errors.As(err, &overloaded) || errors.As(err, &veto)
+
+	b.lock.Lock()
+	now := time.Now()
+	var changed CircuitState
+
+	switch b.state {
+	case CircuitClosed:
+		if ignored {
+			break
+		}
+
+		bucket := b.bucket(now)
+		bucket.total++
+		if err != nil {
+			bucket.failures++
+		}
+
+		total, failures := b.count(now)
+		if total >= b.minRequests && float64(failures) >= b.errorRate*float64(total) {
+			b.state, b.openedAt = CircuitOpen, now
+			changed = CircuitOpen
+		}
+	case CircuitHalfOpen:
+		switch {
+		case ignored:
+			//Give the trial to another execution
+			b.trials--
+		case err != nil:
+			b.state, b.openedAt = CircuitOpen, now
+			changed = CircuitOpen
+		default:
+			b.successes++
+			if b.successes >= b.halfOpenRequests {
+				b.state = CircuitClosed
+				b.buckets = make([]circuitBucket, circuitBuckets)
+				changed = CircuitClosed
+			}
+		}
+	}
+	b.lock.Unlock()
+
+	if len(changed) > 0 {
+		b.onChange(changed)
+	}
+}
+
+//bucket returns the bucket of the time, should be called with lock held
+func (b *breaker) bucket(now time.Time) *circuitBucket {
+	size := b.window / circuitBuckets
+	if size <= 0 {
+		size = 1
+	}
+
+	start := now.Truncate(size)
+	bucket := &b.buckets[int(start.UnixNano()/int64(size))%circuitBuckets]
+	if !bucket.start.Equal(start) {
+		*bucket = circuitBucket{start: start}
+	}
+
+	return bucket
+}
+
+//count the executions and failures in the window, should be called with lock held
+func (b *breaker) count(now time.Time) (int, int) {
+	total, failures := 0, 0
+	for _, bucket := range b.buckets {
+		if now.Sub(bucket.start) < b.window {
+			total += bucket.total
+			failures += bucket.failures
+		}
+	}
+
+	return total, failures
+}
+
+//current returns the current state of the circuit
+func (b *breaker) current() CircuitState {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.state
+}
@@ -0,0 +1,174 @@
+package plugin
+
+import (
+	sys_context "context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/steven-zou/go-plugin/pkg/spec"
+)
+
+var errTestFailure = errors.New("failure")
+
+func TestNewBreaker(t *testing.T) {
+	if b := newBreaker(&spec.Plugin{Name: "billing"}, nil); b != nil {
+		t.Fatal("expect no breaker without the circuit breaker settings")
+	}
+
+	b := newBreaker(&spec.Plugin{Name: "billing", CircuitBreaker: &spec.CircuitBreaker{ErrorRate: 0.5, Window: "invalid"}}, nil)
+	if b.minRequests != DefaultCircuitMinRequests || b.halfOpenRequests != DefaultCircuitHalfOpenRequests {
+		t.Errorf("expect default min requests and half-open requests but got %d and %d", b.minRequests, b.halfOpenRequests)
+	}
+	if b.window != DefaultCircuitWindow || b.openDuration != DefaultCircuitOpenDuration {
+		t.Errorf("expect default window and open duration but got %s and %s", b.window, b.openDuration)
+	}
+	if state := b.current(); state != CircuitClosed {
+		t.Errorf("expect %s circuit but got %s", CircuitClosed, state)
+	}
+}
+
+func TestBreakerRecord(t *testing.T) {
+	canceled := sys_context.Canceled
+	overloaded := &OverloadedError{Plugin: "billing", Version: "1.0.0"}
+	veto := &HookVetoError{Hook: hookBeforeExecute, Plugin: "billing", Version: "1.0.0", Err: errTestFailure}
+
+	cases := []struct {
+		name     string
+		results  []error
+		expected CircuitState
+	}{
+		{"below the min requests", []error{nil, errTestFailure, errTestFailure}, CircuitClosed},
+		{"below the error rate", []error{nil, nil, nil, errTestFailure}, CircuitClosed},
+		{"error rate reached", []error{nil, errTestFailure, errTestFailure, nil}, CircuitOpen},
+		{"cancellation not counted", []error{errTestFailure, errTestFailure, canceled, canceled}, CircuitClosed},
+		{"overloading not counted", []error{errTestFailure, errTestFailure, overloaded, overloaded}, CircuitClosed},
+		{"veto not counted", []error{errTestFailure, errTestFailure, veto, veto}, CircuitClosed},
+		{"ignored errors with the counted ones", []error{veto, errTestFailure, canceled, nil, overloaded, errTestFailure, nil}, CircuitOpen},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			changes := []CircuitState{}
+			b := newBreaker(&spec.Plugin{Name: "billing", Version: "1.0.0", CircuitBreaker: &spec.CircuitBreaker{ErrorRate: 0.5, MinRequests: 4}}, func(state CircuitState) {
+				changes = append(changes, state)
+			})
+
+			for _, err := range c.results {
+				if err := b.allow(); err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				b.record(err)
+			}
+
+			if state := b.current(); state != c.expected {
+				t.Fatalf("expect %s circuit but got %s", c.expected, state)
+			}
+			if c.expected == CircuitClosed {
+				if len(changes) != 0 {
+					t.Fatalf("expect no state changes but got %v", changes)
+				}
+				return
+			}
+
+			if fmt.Sprint(changes) != fmt.Sprint([]CircuitState{CircuitOpen}) {
+				t.Fatalf("expect state changes [%s] but got %v", CircuitOpen, changes)
+			}
+			var ce *CircuitOpenError
+			if err := b.allow(); !errors.As(err, &ce) {
+				t.Fatalf("expect *CircuitOpenError but got %v", err)
+			}
+			if ce.RetryAfter <= 0 || ce.RetryAfter > b.openDuration {
+				t.Errorf("expect retry after in (0, %s] but got %s", b.openDuration, ce.RetryAfter)
+			}
+		})
+	}
+}
+
+func TestBreakerWindow(t *testing.T) {
+	b := newBreaker(&spec.Plugin{Name: "billing", CircuitBreaker: &spec.CircuitBreaker{ErrorRate: 0.5, MinRequests: 2, Window: "1s"}}, nil)
+
+	b.record(errTestFailure)
+
+	//Move the counted failure out of the window
+	b.lock.Lock()
+	for i := range b.buckets {
+		b.buckets[i].start = b.buckets[i].start.Add(-2 * time.Second)
+	}
+	b.lock.Unlock()
+
+	b.record(errTestFailure)
+	if state := b.current(); state != CircuitClosed {
+		t.Fatalf("expect failures out of the window not counted but got %s circuit", state)
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	veto := &HookVetoError{Hook: hookBeforeExecute, Plugin: "billing", Version: "1.0.0", Err: errTestFailure}
+
+	cases := []struct {
+		name     string
+		trials   []error
+		expected []CircuitState
+	}{
+		{"trials succeeded", []error{nil, nil}, []CircuitState{CircuitHalfOpen, CircuitClosed}},
+		{"trial failed", []error{nil, errTestFailure}, []CircuitState{CircuitHalfOpen, CircuitOpen}},
+		{"cancellation gives back the trial", []error{sys_context.Canceled, nil, nil}, []CircuitState{CircuitHalfOpen, CircuitClosed}},
+		{"veto gives back the trial", []error{veto, nil, nil}, []CircuitState{CircuitHalfOpen, CircuitClosed}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			changes := []CircuitState{}
+			b := newBreaker(&spec.Plugin{Name: "billing", Version: "1.0.0", CircuitBreaker: &spec.CircuitBreaker{ErrorRate: 0.5, HalfOpenRequests: 2}}, func(state CircuitState) {
+				changes = append(changes, state)
+			})
+
+			//Opened just the open duration ago
+			b.lock.Lock()
+			b.state, b.openedAt = CircuitOpen, time.Now().Add(-b.openDuration)
+			b.lock.Unlock()
+
+			for _, err := range c.trials {
+				if err := b.allow(); err != nil {
+					t.Fatalf("expect trial execution allowed but got %s", err)
+				}
+				b.record(err)
+			}
+
+			if fmt.Sprint(changes) != fmt.Sprint(c.expected) {
+				t.Fatalf("expect state changes %v but got %v", c.expected, changes)
+			}
+			if state := b.current(); state != c.expected[len(c.expected)-1] {
+				t.Fatalf("expect %s circuit but got %s", c.expected[len(c.expected)-1], state)
+			}
+		})
+	}
+}
+
+func TestBreakerHalfOpenTrials(t *testing.T) {
+	b := newBreaker(&spec.Plugin{Name: "billing", Version: "1.0.0", CircuitBreaker: &spec.CircuitBreaker{ErrorRate: 0.5, MinRequests: 2, HalfOpenRequests: 2}}, func(CircuitState) {})
+	b.lock.Lock()
+	b.state, b.openedAt = CircuitOpen, time.Now().Add(-b.openDuration)
+	b.lock.Unlock()
+
+	//Only the trial budget goes through
+	for i := 0; i < 2; i++ {
+		if err := b.allow(); err != nil {
+			t.Fatalf("expect trial execution %d allowed but got %s", i, err)
+		}
+	}
+	var ce *CircuitOpenError
+	if err := b.allow(); !errors.As(err, &ce) || ce.RetryAfter != 0 {
+		t.Fatalf("expect *CircuitOpenError without retry after when the trials are used up but got %v", err)
+	}
+
+	//The counts before opening are reset after closing
+	b.record(nil)
+	b.record(nil)
+	b.record(errTestFailure)
+	if state := b.current(); state != CircuitClosed {
+		t.Fatalf("expect counts reset after closing but got %s circuit", state)
+	}
+}
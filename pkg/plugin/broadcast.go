@@ -0,0 +1,85 @@
+package plugin
+
+import (
+	"sync"
+)
+
+//DropPolicy defines which value is dropped when the buffer of the subscriber is full
+type DropPolicy int
+
+const (
+	//DropNewest drops the value being published and keeps the buffered ones
+	DropNewest DropPolicy = iota
+
+	//DropOldest drops the oldest buffered value to make room for the one being published
+	DropOldest
+)
+
+//broadcaster publishes the values to all the subscribers.
+//The publishing never blocks, the value is dropped with the drop
+//policy of the subscriber whose buffer is full.
+type broadcaster[T any] struct {
+	//internal lock
+	lock *sync.RWMutex
+
+	//The channels of the subscribers with their drop policies
+	subscribers map[chan T]DropPolicy
+}
+
+func newBroadcaster[T any]() *broadcaster[T] {
+	return &broadcaster[T]{
+		lock:        new(sync.RWMutex),
+		subscribers: make(map[chan T]DropPolicy),
+	}
+}
+
+//subscribe returns the channel receiving the published values with the buffer size
+//and the function to cancel the subscription, which closes the channel.
+func (b *broadcaster[T]) subscribe(buffer int, policy DropPolicy) (<-chan T, func()) {
+	ch := make(chan T, buffer)
+
+	b.lock.Lock()
+	b.subscribers[ch] = policy
+	b.lock.Unlock()
+
+	once := new(sync.Once)
+	return ch, func() {
+		once.Do(func() {
+			b.lock.Lock()
+			delete(b.subscribers, ch)
+			b.lock.Unlock()
+
+			close(ch)
+		})
+	}
+}
+
+//publish the value to the subscribers, return the count of the subscribers dropping a value
+func (b *broadcaster[T]) publish(v T) int {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	dropped := 0
+	for ch, policy := range b.subscribers {
+		select {
+		case ch <- v:
+			continue
+		default:
+			dropped++
+		}
+
+		if policy == DropOldest {
+			//Make room and retry once, give up if the others filled it first
+			select {
+			case <-ch:
+			default:
+			}
+			select {
+			case ch <- v:
+			default:
+			}
+		}
+	}
+
+	return dropped
+}
@@ -0,0 +1,450 @@
+package plugin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/steven-zou/go-plugin/pkg"
+	"github.com/steven-zou/go-plugin/pkg/spec"
+)
+
+const (
+	//DefaultBuildCacheSize is the default max total size (1GiB) of the build cache
+	DefaultBuildCacheSize int64 = 1 << 30
+
+	buildCacheSoExt   = ".so"
+	buildCacheMetaExt = ".json"
+)
+
+//BuildCacheEntry describes one cached so file
+type BuildCacheEntry struct {
+	//The content address of the build
+	Key string `json:"key"`
+
+	//Name of the plugin
+	Plugin string `json:"plugin"`
+
+	//Version of the plugin
+	Version string `json:"version"`
+
+	//Path of the cached so file
+	Path string `json:"path"`
+
+	//Size of the so file in bytes
+	Size int64 `json:"size"`
+
+	//When the entry is created
+	CreatedAt time.Time `json:"created_at"`
+
+	//When the entry is used last time
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+//BuildCache keeps the built plugin so files with the content address
+//of the build inputs, so the unchanged plugin needs not to be rebuilt.
+type BuildCache interface {
+	//Get the path of the cached so file by the key.
+	//If existing, return the path and set the bool flag to true
+	Get(key string) (string, bool)
+
+	//Move the so file into the cache with the key and return the cached path.
+	//The least recently used entries are evicted if the size limit is exceeded.
+	Put(key string, plugin *spec.Plugin, soFile string) (string, error)
+
+	//Return all the cached entries order by the last used time desc
+	Entries() ([]*BuildCacheEntry, error)
+
+	//The total size in bytes of the cached so files
+	Size() (int64, error)
+
+	//Set the max total size in bytes of the cache
+	SetMaxSize(maxSize int64)
+
+	//Remove all the cached entries
+	Purge() error
+}
+
+//BaseBuildCache is the default implementation of BuildCache interface
+//which keeps the so files in a local dir
+type BaseBuildCache struct {
+	//internal lock
+	lock *sync.Mutex
+
+	//The dir where the so files are kept
+	dir string
+
+	//The max total size of the so files
+	maxSize int64
+}
+
+//NewBaseBuildCache is constructor of BaseBuildCache
+func NewBaseBuildCache(dir string, maxSize int64) *BaseBuildCache {
+	return &BaseBuildCache{
+		lock:    new(sync.Mutex),
+		dir:     dir,
+		maxSize: maxSize,
+	}
+}
+
+//Get is the implementation of same method in BuildCache interface
+func (bbc *BaseBuildCache) Get(key string) (string, bool) {
+	bbc.lock.Lock()
+	defer bbc.lock.Unlock()
+
+	soFile := filepath.Join(bbc.dir, key+buildCacheSoExt)
+	if _, err := os.Stat(soFile); err != nil {
+		return "", false
+	}
+
+	//Touch the entry for LRU eviction
+	now := time.Now()
+	os.Chtimes(soFile, now, now)
+
+	return soFile, true
+}
+
+//Put is the implementation of same method in BuildCache interface
+func (bbc *BaseBuildCache) Put(key string, plugin *spec.Plugin, soFile string) (string, error) {
+	if len(key) == 0 {
+		return "", errors.New("empty build cache key")
+	}
+
+	if plugin == nil {
+		return "", errors.New("nil plugin spec")
+	}
+
+	bbc.lock.Lock()
+	defer bbc.lock.Unlock()
+
+	if err := os.MkdirAll(bbc.dir, 0755); err != nil {
+		return "", err
+	}
+
+	cachedFile := filepath.Join(bbc.dir, key+buildCacheSoExt)
+	if err := moveFile(soFile, cachedFile); err != nil {
+		return "", err
+	}
+
+	fi, err := os.Stat(cachedFile)
+	if err != nil {
+		return "", err
+	}
+
+	entry := &BuildCacheEntry{
+		Key:        key,
+		Plugin:     plugin.Name,
+		Version:    plugin.Version,
+		Path:       cachedFile,
+		Size:       fi.Size(),
+		CreatedAt:  fi.ModTime(),
+		LastUsedAt: fi.ModTime(),
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(filepath.Join(bbc.dir, key+buildCacheMetaExt), data, 0644); err != nil {
+		return "", err
+	}
+
+	if err := bbc.evict(key); err != nil {
+		return "", err
+	}
+
+	return cachedFile, nil
+}
+
+//Entries is the implementation of same method in BuildCache interface
+func (bbc *BaseBuildCache) Entries() ([]*BuildCacheEntry, error) {
+	bbc.lock.Lock()
+	defer bbc.lock.Unlock()
+
+	return bbc.entries()
+}
+
+//Size is the implementation of same method in BuildCache interface
+func (bbc *BaseBuildCache) Size() (int64, error) {
+	entries, err := bbc.Entries()
+	if err != nil {
+		return 0, err
+	}
+
+	var size int64
+	for _, e := range entries {
+		size += e.Size
+	}
+
+	return size, nil
+}
+
+//SetMaxSize is the implementation of same method in BuildCache interface
+func (bbc *BaseBuildCache) SetMaxSize(maxSize int64) {
+	bbc.lock.Lock()
+	defer bbc.lock.Unlock()
+
+	bbc.maxSize = maxSize
+}
+
+//Purge is the implementation of same method in BuildCache interface
+func (bbc *BaseBuildCache) Purge() error {
+	bbc.lock.Lock()
+	defer bbc.lock.Unlock()
+
+	return os.RemoveAll(bbc.dir)
+}
+
+//entries lists the cached entries, should be called with lock held
+func (bbc *BaseBuildCache) entries() ([]*BuildCacheEntry, error) {
+	soFiles, err := filepath.Glob(filepath.Join(bbc.dir, "*"+buildCacheSoExt))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*BuildCacheEntry, 0, len(soFiles))
+	for _, soFile := range soFiles {
+		fi, err := os.Stat(soFile)
+		if err != nil {
+			continue
+		}
+
+		key := strings.TrimSuffix(filepath.Base(soFile), buildCacheSoExt)
+		entry := &BuildCacheEntry{
+			Key:       key,
+			CreatedAt: fi.ModTime(),
+		}
+		//The metadata is optional
+		if data, err := ioutil.ReadFile(filepath.Join(bbc.dir, key+buildCacheMetaExt)); err == nil {
+			json.Unmarshal(data, entry)
+		}
+		entry.Path = soFile
+		entry.Size = fi.Size()
+		entry.LastUsedAt = fi.ModTime()
+
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LastUsedAt.After(entries[j].LastUsedAt)
+	})
+
+	return entries, nil
+}
+
+//evict removes the least recently used entries until the total size is under
+//the limit, the entry with the kept key will not be evicted.
+//Should be called with lock held.
+func (bbc *BaseBuildCache) evict(kept string) error {
+	if bbc.maxSize <= 0 {
+		return nil
+	}
+
+	entries, err := bbc.entries()
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	for _, e := range entries {
+		size += e.Size
+	}
+
+	for i := len(entries) - 1; i >= 0 && size > bbc.maxSize; i-- {
+		if entries[i].Key == kept {
+			continue
+		}
+
+		if err := os.Remove(entries[i].Path); err != nil {
+			return err
+		}
+		os.Remove(filepath.Join(bbc.dir, entries[i].Key+buildCacheMetaExt))
+		size -= entries[i].Size
+	}
+
+	return nil
+}
+
+//moveFile renames the file or copies it if they are on different devices
+func moveFile(src string, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	//Copy to a temp file first to avoid exposing the partial file
+	tmp := dst + ".tmp"
+	if _, err := writeFile(tmp, in, 0755, 0); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Remove(src)
+}
+
+//BuildCacheKey computes the content address of a plugin build with the hash
+//of the source tree, the module files (go.mod, go.sum and go.work) enclosing it,
+//the dirs of the packages it depends on out of the source tree (the vendored and
+//the local ones), the toolchain settings and the dependency versions of the host.
+func BuildCacheKey(srcDir string, toolchain []string, depDirs []string) (string, error) {
+	h := sha256.New()
+
+	fmt.Fprintf(h, "toolchain:%s\n", strings.Join(toolchain, " "))
+
+	//The plugin should be built with the same versions of the shared dependencies
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Replace != nil {
+				dep = dep.Replace
+			}
+			fmt.Fprintf(h, "dep:%s@%s %s\n", dep.Path, dep.Version, dep.Sum)
+		}
+	}
+
+	treeHash, err := hashSourceTree(srcDir)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(h, "source:%s\n", treeHash)
+
+	for _, file := range moduleFiles(srcDir) {
+		fileHash, err := hashFiles(filepath.Dir(file), filepath.Base(file))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "module:%s %s\n", filepath.Base(file), fileHash)
+	}
+
+	dirs := append([]string{}, depDirs...)
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		dirHash, err := hashFiles(dir)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "package:%s %s\n", filepath.ToSlash(dir), dirHash)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+//moduleFiles returns the existing module files of the nearest module enclosing the dir
+func moduleFiles(dir string) []string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil
+	}
+
+	for {
+		if pkg.FileExists(filepath.Join(dir, "go.mod")) {
+			files := []string{}
+			for _, name := range []string{"go.mod", "go.sum", "go.work", "go.work.sum"} {
+				if file := filepath.Join(dir, name); pkg.FileExists(file) {
+					files = append(files, file)
+				}
+			}
+			return files
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+}
+
+//hashFiles hashes the names, modes and contents of the regular files directly in the dir,
+//only the named files are hashed if the names are given
+func hashFiles(dir string, names ...string) (string, error) {
+	if len(names) == 0 {
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return "", err
+		}
+		for _, info := range infos {
+			if info.Mode().IsRegular() {
+				names = append(names, info.Name())
+			}
+		}
+	}
+
+	h := sha256.New()
+	for _, name := range names {
+		if err := hashFile(h, filepath.Join(dir, name), name); err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+//hashFile writes the name, mode and content of the file to the hash
+func hashFile(h io.Writer, path string, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(h, "%s %o\n", name, info.Mode().Perm())
+
+	_, err = io.Copy(h, f)
+
+	return err
+}
+
+//hashSourceTree hashes the relative paths, modes and contents of the files in the dir
+func hashSourceTree(srcDir string) (string, error) {
+	h := sha256.New()
+
+	err := filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+
+		return hashFile(h, path, filepath.ToSlash(rel))
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,229 @@
+package plugin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/steven-zou/go-plugin/pkg"
+	"github.com/steven-zou/go-plugin/pkg/spec"
+)
+
+const (
+	//BuildStageClone is the stage of cloning the git repository
+	BuildStageClone = "clone"
+
+	//BuildStageCheckout is the stage of checking out the git ref
+	BuildStageCheckout = "checkout"
+
+	//BuildStageCompile is the stage of compiling the plugin source
+	BuildStageCompile = "compile"
+)
+
+//BuildError is returned when failed to fetch or compile the plugin source.
+//The output of the failed command is kept for troubleshooting.
+type BuildError struct {
+	//Name of the plugin
+	Plugin string
+
+	//The stage where the error occurred
+	Stage string
+
+	//The dir where the command is run
+	Dir string
+
+	//The command line
+	Command []string
+
+	//The combined stdout and stderr of the command
+	Output string
+
+	//The underlying error
+	Err error
+}
+
+//Error implements the error interface
+func (be *BuildError) Error() string {
+	msg := fmt.Sprintf("plugin %s %s failed: '%s': %s", be.Plugin, be.Stage, strings.Join(be.Command, " "), be.Err)
+	if len(be.Output) > 0 {
+		msg = fmt.Sprintf("%s\n%s", msg, be.Output)
+	}
+
+	return msg
+}
+
+//Builder builds the plugin so file from the go source
+type Builder interface {
+	//Build the plugin source under the dir and return the path of the so file
+	Build(plugin *spec.Plugin, srcDir string) (string, error)
+}
+
+//GoBuilder builds the plugin with 'go build -buildmode=plugin'.
+//The built so files are kept in the build cache and reused if
+//the source and the toolchain are not changed.
+type GoBuilder struct {
+	//The cache of the built so files
+	cache BuildCache
+}
+
+//NewGoBuilder is constructor of GoBuilder
+func NewGoBuilder(cache BuildCache) *GoBuilder {
+	return &GoBuilder{
+		cache: cache,
+	}
+}
+
+//Build implements same method of Builder interface
+func (gb *GoBuilder) Build(plugin *spec.Plugin, srcDir string) (string, error) {
+	if plugin == nil {
+		return "", errors.New("nil plugin spec")
+	}
+
+	if gb.cache == nil {
+		return "", errors.New("nil build cache")
+	}
+
+	if !pkg.IsDir(srcDir) {
+		return "", fmt.Errorf("plugin source dir '%s' is not existing", srcDir)
+	}
+
+	toolchain, err := commandOutput(plugin.Name, BuildStageCompile, srcDir, "go", "env", "GOVERSION", "GOOS", "GOARCH", "CGO_ENABLED", "GOFLAGS", "GO111MODULE")
+	if err != nil {
+		return "", err
+	}
+
+	depDirs, err := dependencyDirs(plugin, srcDir)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := BuildCacheKey(srcDir, strings.Split(toolchain, "\n"), depDirs)
+	if err != nil {
+		return "", err
+	}
+
+	if soFile, ok := gb.cache.Get(key); ok {
+		return soFile, nil
+	}
+
+	targets, err := buildTargets(plugin, srcDir)
+	if err != nil {
+		return "", err
+	}
+
+	tmpDir, err := ioutil.TempDir("", "go-plugin-build-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	soFile := filepath.Join(tmpDir, fmt.Sprintf("%s-%s.so", plugin.Name, plugin.Version))
+	if err := runCommand(plugin.Name, BuildStageCompile, srcDir, "go", append([]string{"build", "-buildmode=plugin", "-o", soFile}, targets...)...); err != nil {
+		return "", err
+	}
+
+	return gb.cache.Put(key, plugin, soFile)
+}
+
+//buildTargets returns the go files of the plugin package to build.
+//The go runtime refuses to open two plugins with the same plugin path, which is
+//the import path of the package if it's built as a package, so the versions of
+//the same package could not be loaded side by side. Built with the go files,
+//the plugin path is the hash of the sources and the dependencies instead.
+//The package with non-go sources (e.g: C or assembly files) is built as a package.
+func buildTargets(plugin *spec.Plugin, srcDir string) ([]string, error) {
+	out, err := commandOutput(plugin.Name, BuildStageCompile, srcDir, "go", "list", "-f",
+		"{{if or .CFiles .CXXFiles .MFiles .HFiles .FFiles .SFiles .SwigFiles .SwigCXXFiles .SysoFiles}}.{{else}}{{range .GoFiles}}{{.}}\n{{end}}{{range .CgoFiles}}{{.}}\n{{end}}{{end}}", ".")
+	if err != nil {
+		return nil, err
+	}
+
+	targets := []string{}
+	for _, file := range strings.Split(out, "\n") {
+		if file = strings.TrimSpace(file); len(file) > 0 {
+			targets = append(targets, file)
+		}
+	}
+	if len(targets) == 0 {
+		targets = append(targets, ".")
+	}
+
+	return targets, nil
+}
+
+//dependencyDirs lists the dirs of the non-standard packages the plugin depends on out of
+//the source dir, e.g: the vendored packages and the local ones of the same module or
+//the replaced modules. The packages in the module cache are not listed as they are
+//pinned by the go.mod and go.sum files.
+func dependencyDirs(plugin *spec.Plugin, srcDir string) ([]string, error) {
+	modCache, err := commandOutput(plugin.Name, BuildStageCompile, srcDir, "go", "env", "GOMODCACHE")
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := commandOutput(plugin.Name, BuildStageCompile, srcDir, "go", "list", "-deps", "-f", "{{if not .Standard}}{{.Dir}}{{end}}", ".")
+	if err != nil {
+		return nil, err
+	}
+
+	absSrcDir, err := filepath.Abs(srcDir)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := []string{}
+	for _, dir := range strings.Split(out, "\n") {
+		dir = strings.TrimSpace(dir)
+		if len(dir) == 0 || isSubDir(absSrcDir, dir) || (len(modCache) > 0 && isSubDir(modCache, dir)) {
+			continue
+		}
+		dirs = append(dirs, dir)
+	}
+
+	return dirs, nil
+}
+
+//isSubDir checks if the dir is the parent dir or under it
+func isSubDir(parent string, dir string) bool {
+	rel, err := filepath.Rel(parent, dir)
+	if err != nil {
+		return false
+	}
+
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+//runCommand runs the command under the dir and wrap the failure with BuildError
+func runCommand(plugin string, stage string, dir string, name string, args ...string) error {
+	_, err := commandOutput(plugin, stage, dir, name, args...)
+
+	return err
+}
+
+//commandOutput runs the command under the dir and returns the trimmed output
+func commandOutput(plugin string, stage string, dir string, name string, args ...string) (string, error) {
+	var out bytes.Buffer
+
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	if err := cmd.Run(); err != nil {
+		return "", &BuildError{
+			Plugin:  plugin,
+			Stage:   stage,
+			Dir:     dir,
+			Command: append([]string{name}, args...),
+			Output:  strings.TrimSpace(out.String()),
+			Err:     err,
+		}
+	}
+
+	return strings.TrimSpace(out.String()), nil
+}
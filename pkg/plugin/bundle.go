@@ -0,0 +1,260 @@
+package plugin
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/steven-zou/go-plugin/pkg"
+)
+
+const (
+	//MaxBundleSize is the max total bytes of the files extracted from a bundle
+	MaxBundleSize int64 = 1 << 30
+
+	//MaxBundleFileSize is the max bytes of a single file extracted from a bundle
+	MaxBundleFileSize int64 = 512 << 20
+)
+
+//BundleExtractor extracts the plugin bundle ('*.plg') into the managed cache dir.
+//The bundle is a gzip compressed tarball which contains the 'plugin.json',
+//the 'so' file and other optional assets at the root level.
+type BundleExtractor struct {
+	//The dir where the bundles are extracted to
+	cacheDir string
+}
+
+//NewBundleExtractor is constructor of BundleExtractor
+func NewBundleExtractor(cacheDir string) *BundleExtractor {
+	return &BundleExtractor{
+		cacheDir: cacheDir,
+	}
+}
+
+//Extract the bundle into the cache dir and return the path of the extracted plugin dir.
+//The extracted dir is named with the bundle file name without the extension and put
+//under the dir named with the digest of the bundle content, e.g: '<digest>/billing@1.0.0'.
+//The bundle with the same content is extracted only once and the extracted dir is reused,
+//the dirs of the previous contents are kept as their 'so' files may be still opened.
+func (be *BundleExtractor) Extract(bundlePath string) (string, error) {
+	if !pkg.IsBundle(bundlePath) {
+		return "", fmt.Errorf("%s is not a plugin bundle file", bundlePath)
+	}
+
+	if len(be.cacheDir) == 0 {
+		return "", errors.New("bundle cache dir is not set")
+	}
+
+	digest, err := fileDigest(bundlePath)
+	if err != nil {
+		return "", err
+	}
+
+	name := strings.TrimSuffix(filepath.Base(bundlePath), pkg.PluginBundleExt)
+	digestDir := filepath.Join(be.cacheDir, digest[:16])
+	pluginDir := filepath.Join(digestDir, name)
+	if pkg.IsDir(pluginDir) {
+		//Already extracted
+		return pluginDir, nil
+	}
+
+	if err := os.MkdirAll(digestDir, 0755); err != nil {
+		return "", err
+	}
+
+	tmpDir, err := ioutil.TempDir(digestDir, "."+name+"-")
+	if err != nil {
+		return "", err
+	}
+
+	if err := untar(bundlePath, tmpDir, MaxBundleSize, MaxBundleFileSize); err != nil {
+		os.RemoveAll(tmpDir)
+		return "", fmt.Errorf("failed to extract bundle %s: %s", bundlePath, err)
+	}
+
+	if err := os.Rename(tmpDir, pluginDir); err != nil {
+		os.RemoveAll(tmpDir)
+		if pkg.IsDir(pluginDir) {
+			//Extracted by the concurrent loading
+			return pluginDir, nil
+		}
+		return "", err
+	}
+
+	return pluginDir, nil
+}
+
+//Pack the plugin dir into a bundle file
+func (be *BundleExtractor) Pack(pluginDir string, bundlePath string) error {
+	if !pkg.IsDir(pluginDir) {
+		return fmt.Errorf("%s is not a plugin dir", pluginDir)
+	}
+
+	if filepath.Ext(bundlePath) != pkg.PluginBundleExt {
+		return fmt.Errorf("bundle file should have extension %s", pkg.PluginBundleExt)
+	}
+
+	f, err := os.Create(bundlePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+
+	err = filepath.Walk(pluginDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(pluginDir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+
+		if !info.Mode().IsDir() && !info.Mode().IsRegular() {
+			//Only dirs and regular files are packed
+			return nil
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if info.Mode().IsRegular() {
+			src, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer src.Close()
+
+			if _, err := io.Copy(tw, src); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gw.Close()
+}
+
+//untar extracts the bundle into the target dir, the extracted files should not be
+//larger than maxFileSize each and maxSize in total
+func untar(bundlePath string, targetDir string, maxSize int64, maxFileSize int64) error {
+	f, err := os.Open(bundlePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+
+	tr := tar.NewReader(gr)
+	left := maxSize
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		//Avoid writing files out of the target dir
+		name := filepath.Clean(filepath.FromSlash(header.Name))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("illegal file path %s in bundle", header.Name)
+		}
+		target := filepath.Join(targetDir, name)
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if header.Size > maxFileSize || header.Size > left {
+				return fmt.Errorf("file %s in bundle is too large", header.Name)
+			}
+			written, err := writeFile(target, tr, os.FileMode(header.Mode).Perm(), header.Size)
+			if err != nil {
+				return err
+			}
+			left -= written
+		default:
+			return fmt.Errorf("unsupported file type of %s in bundle", header.Name)
+		}
+	}
+}
+
+//writeFile copies at most limit bytes from the reader to the target file,
+//zero limit means no limit
+func writeFile(target string, r io.Reader, perm os.FileMode, limit int64) (int64, error) {
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	if limit <= 0 {
+		return io.Copy(f, r)
+	}
+
+	written, err := io.Copy(f, io.LimitReader(r, limit+1))
+	if err != nil {
+		return written, err
+	}
+	if written > limit {
+		return written, fmt.Errorf("file %s exceeds the size %d", filepath.Base(target), limit)
+	}
+
+	return written, nil
+}
+
+//fileDigest returns the hex sha256 digest of the file content
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,287 @@
+package plugin
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+//tarEntry is an entry of the bundle built in memory
+type tarEntry struct {
+	name     string
+	typeflag byte
+	body     string
+	linkname string
+}
+
+//writeBundle writes the gzip compressed tarball of the entries to the path
+func writeBundle(t *testing.T, path string, entries ...tarEntry) {
+	buf := new(bytes.Buffer)
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	for _, e := range entries {
+		header := &tar.Header{
+			Name:     e.name,
+			Typeflag: e.typeflag,
+			Linkname: e.linkname,
+			Mode:     0644,
+			Size:     int64(len(e.body)),
+		}
+		if e.typeflag != tar.TypeReg {
+			header.Size = 0
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUntar(t *testing.T) {
+	cases := []struct {
+		name        string
+		entries     []tarEntry
+		maxSize     int64
+		maxFileSize int64
+		files       []string
+		err         string
+	}{
+		{
+			name: "regular files and dirs",
+			entries: []tarEntry{
+				{name: "plugin.json", typeflag: tar.TypeReg, body: "{}"},
+				{name: "assets", typeflag: tar.TypeDir},
+				{name: "assets/a.txt", typeflag: tar.TypeReg, body: "a"},
+				{name: "nested/b.txt", typeflag: tar.TypeReg, body: "b"},
+			},
+			files: []string{"plugin.json", "assets/a.txt", "nested/b.txt"},
+		},
+		{
+			name:    "cleaned path in the dir",
+			entries: []tarEntry{{name: "assets/../plugin.json", typeflag: tar.TypeReg, body: "{}"}},
+			files:   []string{"plugin.json"},
+		},
+		{
+			name:    "parent dir",
+			entries: []tarEntry{{name: "../evil", typeflag: tar.TypeReg, body: "x"}},
+			err:     "illegal file path",
+		},
+		{
+			name:    "nested parent dir",
+			entries: []tarEntry{{name: "assets/../../evil", typeflag: tar.TypeReg, body: "x"}},
+			err:     "illegal file path",
+		},
+		{
+			name:    "parent dir itself",
+			entries: []tarEntry{{name: "..", typeflag: tar.TypeDir}},
+			err:     "illegal file path",
+		},
+		{
+			name:    "absolute path",
+			entries: []tarEntry{{name: "/tmp/evil", typeflag: tar.TypeReg, body: "x"}},
+			err:     "illegal file path",
+		},
+		{
+			name:    "symlink",
+			entries: []tarEntry{{name: "link", typeflag: tar.TypeSymlink, linkname: "/etc/passwd"}},
+			err:     "unsupported file type",
+		},
+		{
+			name: "hardlink",
+			entries: []tarEntry{
+				{name: "plugin.json", typeflag: tar.TypeReg, body: "{}"},
+				{name: "link", typeflag: tar.TypeLink, linkname: "plugin.json"},
+			},
+			err: "unsupported file type",
+		},
+		{
+			name:    "device",
+			entries: []tarEntry{{name: "dev", typeflag: tar.TypeChar}},
+			err:     "unsupported file type",
+		},
+		{
+			name:        "file too large",
+			entries:     []tarEntry{{name: "big.so", typeflag: tar.TypeReg, body: "0123456789"}},
+			maxFileSize: 9,
+			err:         "too large",
+		},
+		{
+			name: "total too large",
+			entries: []tarEntry{
+				{name: "a.so", typeflag: tar.TypeReg, body: "01234"},
+				{name: "b.so", typeflag: tar.TypeReg, body: "56789"},
+			},
+			maxSize: 9,
+			err:     "too large",
+		},
+		{
+			name: "at the limits",
+			entries: []tarEntry{
+				{name: "a.so", typeflag: tar.TypeReg, body: "01234"},
+				{name: "b.so", typeflag: tar.TypeReg, body: "56789"},
+			},
+			maxSize:     10,
+			maxFileSize: 5,
+			files:       []string{"a.so", "b.so"},
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "untar-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			caseDir, err := ioutil.TempDir(dir, "case-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			bundle := filepath.Join(caseDir, "billing@1.0.0.plg")
+			writeBundle(t, bundle, c.entries...)
+
+			maxSize, maxFileSize := c.maxSize, c.maxFileSize
+			if maxSize == 0 {
+				maxSize = MaxBundleSize
+			}
+			if maxFileSize == 0 {
+				maxFileSize = MaxBundleFileSize
+			}
+
+			target := filepath.Join(caseDir, "target")
+			if err := os.Mkdir(target, 0755); err != nil {
+				t.Fatal(err)
+			}
+			err = untar(bundle, target, maxSize, maxFileSize)
+			if len(c.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("expect error containing %q but got %v", c.err, err)
+				}
+				//Nothing is written out of the target dir
+				if _, err := os.Lstat(filepath.Join(caseDir, "evil")); err == nil {
+					t.Fatal("expect no file written out of the target dir")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			for _, f := range c.files {
+				if info, err := os.Lstat(filepath.Join(target, filepath.FromSlash(f))); err != nil || !info.Mode().IsRegular() {
+					t.Errorf("expect regular file %s extracted but got %v", f, err)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteFileLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "write-file-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		content string
+		limit   int64
+		failed  bool
+	}{
+		{"0123456789", 0, false},
+		{"0123456789", 10, false},
+		{"0123456789", 9, true},
+	}
+	for _, c := range cases {
+		written, err := writeFile(filepath.Join(dir, "f"), strings.NewReader(c.content), 0644, c.limit)
+		if c.failed != (err != nil) {
+			t.Errorf("limit %d: expect failed %v but got %v", c.limit, c.failed, err)
+		}
+		if !c.failed && written != int64(len(c.content)) {
+			t.Errorf("limit %d: expect %d bytes written but got %d", c.limit, len(c.content), written)
+		}
+	}
+}
+
+func TestExtractReuse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "extract-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	be := NewBundleExtractor(filepath.Join(dir, "cache"))
+	bundle := filepath.Join(dir, "billing@1.0.0.plg")
+
+	writeBundle(t, bundle, tarEntry{name: "plugin.json", typeflag: tar.TypeReg, body: "v1"})
+	first, err := be.Extract(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(first) != "billing@1.0.0" {
+		t.Fatalf("expect dir named with the bundle but got %s", first)
+	}
+
+	//Same content is extracted once
+	marker := filepath.Join(first, "marker")
+	if err := ioutil.WriteFile(marker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	again, err := be.Extract(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != first {
+		t.Fatalf("expect extracted dir %s reused but got %s", first, again)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Fatal("expect the extracted dir not touched")
+	}
+
+	//Changed content is extracted to another dir, the previous one is kept
+	writeBundle(t, bundle, tarEntry{name: "plugin.json", typeflag: tar.TypeReg, body: "v2"})
+	changed, err := be.Extract(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed == first || filepath.Base(changed) != "billing@1.0.0" {
+		t.Fatalf("expect new dir for the changed bundle but got %s", changed)
+	}
+	if content, err := ioutil.ReadFile(filepath.Join(changed, "plugin.json")); err != nil || string(content) != "v2" {
+		t.Fatalf("expect changed content extracted but got %q, %v", content, err)
+	}
+	if _, err := os.Stat(first); err != nil {
+		t.Fatal("expect the dir of the previous content kept")
+	}
+
+	//Failed extraction leaves nothing
+	evil := filepath.Join(dir, "evil@1.0.0.plg")
+	writeBundle(t, evil, tarEntry{name: "../evil", typeflag: tar.TypeReg, body: "x"})
+	if _, err := be.Extract(evil); err == nil {
+		t.Fatal("expect error for the illegal bundle")
+	}
+	digest, err := fileDigest(evil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := ioutil.ReadDir(filepath.Join(dir, "cache", digest[:16])); len(entries) != 0 {
+		t.Fatalf("expect no leftovers of the failed extraction but got %d", len(entries))
+	}
+}
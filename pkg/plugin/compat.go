@@ -0,0 +1,201 @@
+package plugin
+
+import (
+	"debug/buildinfo"
+	"errors"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	"github.com/steven-zou/go-plugin/pkg"
+	"github.com/steven-zou/go-plugin/pkg/spec"
+)
+
+//ModuleMismatch describes a module shared by the host and the plugin with different versions
+type ModuleMismatch struct {
+	//The module path
+	Path string
+
+	//The module version used by the host
+	HostVersion string
+
+	//The module version used by the plugin
+	PluginVersion string
+}
+
+//IncompatibleError is returned when the plugin so file is built with
+//a different go version or different versions of the shared modules.
+type IncompatibleError struct {
+	//Path of the plugin so file
+	Path string
+
+	//The go version of the host
+	HostGoVersion string
+
+	//The go version of the plugin, it's same with the host one if matched
+	PluginGoVersion string
+
+	//The shared modules with mismatched versions
+	Modules []*ModuleMismatch
+}
+
+//Error implements the error interface
+func (ie *IncompatibleError) Error() string {
+	problems := make([]string, 0, len(ie.Modules)+1)
+	if ie.HostGoVersion != ie.PluginGoVersion {
+		problems = append(problems, fmt.Sprintf("go version: host %s vs plugin %s", ie.HostGoVersion, ie.PluginGoVersion))
+	}
+	for _, m := range ie.Modules {
+		problems = append(problems, fmt.Sprintf("module %s: host %s vs plugin %s", m.Path, m.HostVersion, m.PluginVersion))
+	}
+
+	return fmt.Sprintf("plugin so file %s is incompatible with the host: %s", ie.Path, strings.Join(problems, "; "))
+}
+
+//NoModuleInfoError is returned when the versions of the shared modules can not be
+//compared as the host or the plugin so file is built without the module info,
+//e.g: built in the GOPATH mode. Only the go version is checked in this case.
+type NoModuleInfoError struct {
+	//Path of the plugin so file
+	Path string
+
+	//Which one is built without the module info, 'host' or 'plugin'
+	Side string
+}
+
+//Error implements the error interface
+func (ne *NoModuleInfoError) Error() string {
+	return fmt.Sprintf("module versions of plugin so file %s can not be checked: the %s is built without module info", ne.Path, ne.Side)
+}
+
+//CheckCompatibility reads the build info embedded in the plugin so file and
+//compares the go version and the versions of the shared modules with the host.
+//The main modules of the host and the plugin are compared as the shared ones too,
+//e.g: the plugin built in this module is checked against the version of this
+//module used by the host. If any mismatches are found, an *IncompatibleError
+//will be returned. If the host or the plugin has no module info, the go version
+//is still checked and a *NoModuleInfoError is returned if it's matched.
+func CheckCompatibility(soFile string) error {
+	if !pkg.FileExists(soFile) {
+		return fmt.Errorf("plugin so file '%s' is not existsing", soFile)
+	}
+
+	pluginInfo, err := buildinfo.ReadFile(soFile)
+	if err != nil {
+		return fmt.Errorf("failed to read build info of plugin so file '%s': %s", soFile, err)
+	}
+
+	incompatible := &IncompatibleError{
+		Path:            soFile,
+		HostGoVersion:   runtime.Version(),
+		PluginGoVersion: pluginInfo.GoVersion,
+		Modules:         make([]*ModuleMismatch, 0),
+	}
+
+	var noModuleInfo error
+	hostInfo, ok := debug.ReadBuildInfo()
+	switch {
+	case !ok || !hasModuleInfo(hostInfo):
+		noModuleInfo = &NoModuleInfoError{Path: soFile, Side: "host"}
+	case !hasModuleInfo(pluginInfo):
+		noModuleInfo = &NoModuleInfoError{Path: soFile, Side: "plugin"}
+	default:
+		incompatible.Modules = compareModules(hostInfo, pluginInfo)
+	}
+
+	if incompatible.HostGoVersion != incompatible.PluginGoVersion || len(incompatible.Modules) > 0 {
+		return incompatible
+	}
+
+	return noModuleInfo
+}
+
+//compareModules returns the modules shared by the host and the plugin with different versions
+func compareModules(hostInfo *debug.BuildInfo, pluginInfo *debug.BuildInfo) []*ModuleMismatch {
+	mismatches := make([]*ModuleMismatch, 0)
+	hostModules := modules(hostInfo)
+	for path, pluginVersion := range modules(pluginInfo) {
+		hostVersion, shared := hostModules[path]
+		if !shared || pluginVersion == hostVersion {
+			continue
+		}
+
+		mismatches = append(mismatches, &ModuleMismatch{
+			Path:          path,
+			HostVersion:   hostVersion,
+			PluginVersion: pluginVersion,
+		})
+	}
+	sort.Slice(mismatches, func(i, j int) bool {
+		return mismatches[i].Path < mismatches[j].Path
+	})
+
+	return mismatches
+}
+
+//hasModuleInfo checks if the binary is built with the module info
+func hasModuleInfo(info *debug.BuildInfo) bool {
+	return len(info.Main.Path) > 0 || len(info.Deps) > 0
+}
+
+//modules returns the versions of the main module and the dependencies by the module paths
+func modules(info *debug.BuildInfo) map[string]string {
+	versions := make(map[string]string, len(info.Deps)+1)
+	if len(info.Main.Path) > 0 {
+		versions[info.Main.Path] = moduleVersion(&info.Main)
+	}
+	for _, dep := range info.Deps {
+		versions[dep.Path] = moduleVersion(dep)
+	}
+
+	return versions
+}
+
+//moduleVersion returns the version of the module, the replacement is also considered
+func moduleVersion(m *debug.Module) string {
+	if m.Replace == nil {
+		return m.Version
+	}
+
+	if len(m.Replace.Version) == 0 {
+		//Replaced by local dir
+		return fmt.Sprintf("%s => %s", m.Version, m.Replace.Path)
+	}
+
+	return fmt.Sprintf("%s => %s@%s", m.Version, m.Replace.Path, m.Replace.Version)
+}
+
+//CompatibilityValidator validates the compatibility of the local so file with the host
+type CompatibilityValidator struct{}
+
+//Validate is the implementation of Validator interface
+func (cv *CompatibilityValidator) Validate(params ...interface{}) (interface{}, error) {
+	if len(params) == 0 {
+		return nil, errors.New("plugin json object is missing")
+	}
+
+	pluginSpec, ok := params[0].(*spec.Plugin)
+	if !ok {
+		return nil, errors.New("invalid plugin spec object")
+	}
+
+	if pluginSpec.Source == nil {
+		return nil, errors.New("plugin source missing")
+	}
+
+	//Only the existing so file can be checked before loading,
+	//the built ones are checked by the loader.
+	if pluginSpec.Source.Mode != pkg.PluginSourceModeLocal {
+		return pluginSpec, nil
+	}
+
+	//The missing module info is reported by the loader
+	var noModuleInfo *NoModuleInfoError
+	if err := CheckCompatibility(pluginSpec.Source.Path); err != nil && !errors.As(err, &noModuleInfo) {
+		return nil, err
+	}
+
+	return pluginSpec, nil
+}
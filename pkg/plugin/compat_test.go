@@ -0,0 +1,88 @@
+package plugin
+
+import (
+	"fmt"
+	"runtime/debug"
+	"testing"
+)
+
+func TestCompareModules(t *testing.T) {
+	host := &debug.BuildInfo{
+		Main: debug.Module{Path: "example.com/host", Version: "(devel)"},
+		Deps: []*debug.Module{
+			{Path: "github.com/steven-zou/go-plugin", Version: "v1.2.0"},
+			{Path: "github.com/Masterminds/semver", Version: "v1.4.2"},
+			{Path: "golang.org/x/sys", Version: "v0.1.0"},
+		},
+	}
+
+	cases := []struct {
+		name     string
+		plugin   *debug.BuildInfo
+		expected string
+	}{
+		{
+			name: "same versions",
+			plugin: &debug.BuildInfo{
+				Main: debug.Module{Path: "example.com/billing", Version: "(devel)"},
+				Deps: []*debug.Module{{Path: "github.com/steven-zou/go-plugin", Version: "v1.2.0"}},
+			},
+			expected: "[]",
+		},
+		{
+			name: "different dependency",
+			plugin: &debug.BuildInfo{
+				Main: debug.Module{Path: "example.com/billing", Version: "(devel)"},
+				Deps: []*debug.Module{
+					{Path: "golang.org/x/sys", Version: "v0.2.0"},
+					{Path: "github.com/steven-zou/go-plugin", Version: "v1.1.0"},
+				},
+			},
+			expected: "[github.com/steven-zou/go-plugin v1.2.0 v1.1.0 golang.org/x/sys v0.1.0 v0.2.0]",
+		},
+		{
+			name: "main module of plugin",
+			plugin: &debug.BuildInfo{
+				Main: debug.Module{Path: "github.com/steven-zou/go-plugin", Version: "(devel)"},
+			},
+			expected: "[github.com/steven-zou/go-plugin v1.2.0 (devel)]",
+		},
+		{
+			name: "replaced dependency",
+			plugin: &debug.BuildInfo{
+				Main: debug.Module{Path: "example.com/billing", Version: "(devel)"},
+				Deps: []*debug.Module{{
+					Path:    "github.com/Masterminds/semver",
+					Version: "v1.4.2",
+					Replace: &debug.Module{Path: "../semver"},
+				}},
+			},
+			expected: "[github.com/Masterminds/semver v1.4.2 v1.4.2 => ../semver]",
+		},
+		{
+			name: "not shared",
+			plugin: &debug.BuildInfo{
+				Main: debug.Module{Path: "example.com/billing", Version: "v0.1.0"},
+				Deps: []*debug.Module{{Path: "golang.org/x/text", Version: "v0.3.0"}},
+			},
+			expected: "[]",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mismatches := compareModules(host, c.plugin)
+			got := make([]string, 0, len(mismatches))
+			for _, m := range mismatches {
+				got = append(got, m.Path, m.HostVersion, m.PluginVersion)
+			}
+			if s := fmt.Sprint(got); s != c.expected {
+				t.Errorf("expect mismatches %s but got %s", c.expected, s)
+			}
+		})
+	}
+
+	if hasModuleInfo(&debug.BuildInfo{}) {
+		t.Error("expect no module info of the GOPATH build")
+	}
+}
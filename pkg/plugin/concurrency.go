@@ -0,0 +1,214 @@
+package plugin
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/steven-zou/go-plugin/pkg/context"
+	"github.com/steven-zou/go-plugin/pkg/spec"
+)
+
+//OverloadedError is returned when the execution is rejected by the
+//concurrency limits of the plugin
+type OverloadedError struct {
+	//Name of the plugin
+	Plugin string
+
+	//Version of the plugin
+	Version string
+
+	//The max executions running at the same time
+	MaxInFlight int
+
+	//The max executions waiting in the queue
+	QueueLength int
+
+	//Why the execution is rejected
+	Reason string
+}
+
+//Error implements the error interface
+func (oe *OverloadedError) Error() string {
+	return fmt.Sprintf("plugin %s:%s is overloaded (max in-flight %d, queue length %d): %s", oe.Plugin, oe.Version, oe.MaxInFlight, oe.QueueLength, oe.Reason)
+}
+
+//ConcurrencyStats is the snapshot of the concurrent executions of the plugin
+type ConcurrencyStats struct {
+	//Name of the plugin
+	Plugin string
+
+	//Version of the plugin
+	Version string
+
+	//The max executions running at the same time, zero means not limited
+	MaxInFlight int
+
+	//The max executions waiting in the queue
+	QueueLength int
+
+	//The executions running now
+	InFlight int
+
+	//The executions waiting in the queue now
+	Queued int
+
+	//The count of the rejected executions
+	Rejected uint64
+}
+
+//limiter enforces the concurrency limits of a plugin item
+type limiter struct {
+	//The limited plugin
+	plugin *spec.Plugin
+
+	//The slots of the running executions
+	slots chan struct{}
+
+	//The max executions waiting in the queue
+	queueLength int
+
+	//The max time waiting in the queue, zero means no timeout
+	queueTimeout time.Duration
+
+	//internal lock
+	lock *sync.Mutex
+
+	//The executions waiting in the queue
+	queued int
+
+	//The count of the rejected executions
+	rejected uint64
+}
+
+//newLimiter creates the limiter with the concurrency settings of the plugin,
+//nil is returned if the plugin is not limited
+func newLimiter(plugin *spec.Plugin) *limiter {
+	c := plugin.Concurrency
+	if c == nil || c.MaxInFlight <= 0 {
+		return nil
+	}
+
+	l := &limiter{
+		plugin:      plugin,
+		slots:       make(chan struct{}, c.MaxInFlight),
+		queueLength: c.QueueLength,
+		lock:        new(sync.Mutex),
+	}
+	if len(c.QueueTimeout) > 0 {
+		l.queueTimeout, _ = time.ParseDuration(c.QueueTimeout)
+	}
+
+	return l
+}
+
+//wrap the executor to enforce the limits, nil limiter does not limit
+func (l *limiter) wrap(exec spec.PluginExecutor) spec.PluginExecutor {
+	if l == nil {
+		return exec
+	}
+
+	return func(ctx context.PluginContext) error {
+		if err := l.acquire(ctx); err != nil {
+			return err
+		}
+		defer l.release()
+
+		return exec(ctx)
+	}
+}
+
+//acquire a slot of running, waits in the queue if no free slots
+func (l *limiter) acquire(ctx context.PluginContext) error {
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	l.lock.Lock()
+	if l.queued >= l.queueLength {
+		l.rejected++
+		l.lock.Unlock()
+		return l.overloaded("queue is full")
+	}
+	l.queued++
+	l.lock.Unlock()
+
+	defer func() {
+		l.lock.Lock()
+		l.queued--
+		l.lock.Unlock()
+	}()
+
+	var timeout <-chan time.Time
+	if l.queueTimeout > 0 {
+		timer := time.NewTimer(l.queueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-timeout:
+		l.lock.Lock()
+		l.rejected++
+		l.lock.Unlock()
+		return l.overloaded(fmt.Sprintf("queue timeout after %s", l.queueTimeout))
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+//release the slot of running
+func (l *limiter) release() {
+	<-l.slots
+}
+
+func (l *limiter) overloaded(reason string) error {
+	return &OverloadedError{
+		Plugin:      l.plugin.Name,
+		Version:     l.plugin.Version,
+		MaxInFlight: cap(l.slots),
+		QueueLength: l.queueLength,
+		Reason:      reason,
+	}
+}
+
+//stats fills the limits and the queue of the limiter
+func (l *limiter) stats(stats *ConcurrencyStats) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	stats.MaxInFlight = cap(l.slots)
+	stats.QueueLength = l.queueLength
+	stats.InFlight = len(l.slots)
+	stats.Queued = l.queued
+	stats.Rejected = l.rejected
+}
+
+//GetConcurrency implements the interface method
+func (bm *BaseManager) GetConcurrency(name string, version string) (*ConcurrencyStats, error) {
+	pluginItem, ok := bm.store.GetVersion(name, version)
+	if !ok {
+		return nil, fmt.Errorf("plugin '%s' is not existing", pluginRef(name, version))
+	}
+
+	stats := &ConcurrencyStats{
+		Plugin:  pluginItem.Spec.Name,
+		Version: pluginItem.Spec.Version,
+	}
+
+	if state := bm.executionOf(pluginItem); state != nil {
+		if state.limits != nil {
+			state.limits.stats(stats)
+		} else {
+			state.running.lock.Lock()
+			stats.InFlight = state.running.running
+			state.running.lock.Unlock()
+		}
+	}
+
+	return stats, nil
+}
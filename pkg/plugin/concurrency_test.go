@@ -0,0 +1,180 @@
+package plugin
+
+import (
+	sys_context "context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/steven-zou/go-plugin/pkg/context"
+	"github.com/steven-zou/go-plugin/pkg/spec"
+)
+
+func TestNewLimiter(t *testing.T) {
+	if l := newLimiter(&spec.Plugin{Name: "billing"}); l != nil {
+		t.Fatal("expect no limiter without the concurrency settings")
+	}
+	if l := newLimiter(&spec.Plugin{Name: "billing", Concurrency: &spec.Concurrency{}}); l != nil {
+		t.Fatal("expect no limiter without the max in-flight")
+	}
+
+	var l *limiter
+	called := false
+	exec := l.wrap(func(ctx context.PluginContext) error {
+		called = true
+		return nil
+	})
+	if err := exec(context.Background()); err != nil || !called {
+		t.Fatalf("expect nil limiter not limiting the executor but got %v", err)
+	}
+}
+
+func TestLimiterAcquire(t *testing.T) {
+	cases := []struct {
+		name        string
+		concurrency *spec.Concurrency
+		//The executions waiting in the queue before acquiring
+		queued int
+		//Cancel the context after the period
+		cancel time.Duration
+		//The min period waiting in the queue
+		wait     time.Duration
+		reason   string
+		err      error
+		expected ConcurrencyStats
+	}{
+		{
+			name:        "free slot",
+			concurrency: &spec.Concurrency{MaxInFlight: 2},
+			expected:    ConcurrencyStats{InFlight: 2},
+		},
+		{
+			name:        "queue full",
+			concurrency: &spec.Concurrency{MaxInFlight: 1},
+			reason:      "queue is full",
+			expected:    ConcurrencyStats{InFlight: 1, Rejected: 1},
+		},
+		{
+			name:        "queue full with the waiting one",
+			concurrency: &spec.Concurrency{MaxInFlight: 1, QueueLength: 1},
+			queued:      1,
+			reason:      "queue is full",
+			expected:    ConcurrencyStats{InFlight: 1, Queued: 1, Rejected: 1},
+		},
+		{
+			name:        "queue timeout",
+			concurrency: &spec.Concurrency{MaxInFlight: 1, QueueLength: 1, QueueTimeout: "20ms"},
+			wait:        20 * time.Millisecond,
+			reason:      "queue timeout after 20ms",
+			expected:    ConcurrencyStats{InFlight: 1, Rejected: 1},
+		},
+		{
+			//The canceled one is not counted as rejected
+			name:        "context canceled",
+			concurrency: &spec.Concurrency{MaxInFlight: 1, QueueLength: 1},
+			cancel:      20 * time.Millisecond,
+			wait:        20 * time.Millisecond,
+			err:         sys_context.Canceled,
+			expected:    ConcurrencyStats{InFlight: 1},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			l := newLimiter(&spec.Plugin{Name: "billing", Version: "1.0.0", Concurrency: c.concurrency})
+
+			//Occupy a slot
+			if err := l.acquire(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			for i := 0; i < c.queued; i++ {
+				go l.acquire(context.Background())
+			}
+			deadline := time.Now().Add(time.Second)
+			for stats := (ConcurrencyStats{}); ; {
+				l.stats(&stats)
+				if stats.Queued == c.queued {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("expect %d queued executions but got %d", c.queued, stats.Queued)
+				}
+				time.Sleep(time.Millisecond)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if c.cancel > 0 {
+				time.AfterFunc(c.cancel, cancel)
+			}
+
+			start := time.Now()
+			err := l.acquire(ctx)
+			elapsed := time.Since(start)
+
+			var oe *OverloadedError
+			switch {
+			case len(c.reason) > 0:
+				if !errors.As(err, &oe) || !strings.Contains(oe.Reason, c.reason) {
+					t.Fatalf("expect *OverloadedError with reason %q but got %v", c.reason, err)
+				}
+				if oe.Plugin != "billing" || oe.Version != "1.0.0" {
+					t.Fatalf("expect overloaded plugin billing:1.0.0 but got %s:%s", oe.Plugin, oe.Version)
+				}
+			case c.err != nil:
+				if !errors.Is(err, c.err) {
+					t.Fatalf("expect %v but got %v", c.err, err)
+				}
+			case err != nil:
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if elapsed < c.wait {
+				t.Fatalf("expect waiting in the queue for %s but returned after %s", c.wait, elapsed)
+			}
+
+			stats := ConcurrencyStats{}
+			l.stats(&stats)
+			if stats.InFlight != c.expected.InFlight || stats.Queued != c.expected.Queued || stats.Rejected != c.expected.Rejected {
+				t.Fatalf("expect %+v but got %+v", c.expected, stats)
+			}
+		})
+	}
+}
+
+func TestLimiterRelease(t *testing.T) {
+	l := newLimiter(&spec.Plugin{Name: "billing", Version: "1.0.0", Concurrency: &spec.Concurrency{MaxInFlight: 1, QueueLength: 1}})
+	if err := l.acquire(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- l.acquire(context.Background())
+	}()
+
+	//The queued execution gets the slot after release
+	deadline := time.Now().Add(time.Second)
+	for stats := (ConcurrencyStats{}); stats.Queued != 1; l.stats(&stats) {
+		if time.Now().After(deadline) {
+			t.Fatal("expect the execution queued")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	l.release()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("expect the queued execution to get the slot but got %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expect the queued execution to get the slot after release")
+	}
+
+	stats := ConcurrencyStats{}
+	l.stats(&stats)
+	if stats.InFlight != 1 || stats.Queued != 0 {
+		t.Fatalf("expect 1 in-flight and 0 queued but got %+v", stats)
+	}
+}
@@ -0,0 +1,149 @@
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/steven-zou/go-plugin/pkg/spec"
+)
+
+//The constraint matching any version
+const anyVersion = "*"
+
+//NoMatchingVersionError is returned when none of the loaded versions
+//of the plugin satisfies the semver constraint
+type NoMatchingVersionError struct {
+	//Name of the plugin
+	Plugin string
+
+	//The semver constraint
+	Constraint string
+
+	//The loaded versions of the plugin
+	Available []string
+}
+
+//Error implements the error interface
+func (nmv *NoMatchingVersionError) Error() string {
+	if len(nmv.Available) == 0 {
+		return fmt.Sprintf("no version of plugin '%s' matches '%s': no versions loaded", nmv.Plugin, nmv.Constraint)
+	}
+
+	return fmt.Sprintf("no version of plugin '%s' matches '%s': available versions [%s]", nmv.Plugin, nmv.Constraint, strings.Join(nmv.Available, ", "))
+}
+
+//RequirementsError is returned when some of the required plugins are not satisfied
+type RequirementsError struct {
+	//The errors of the unsatisfied requirements
+	Errors []error
+}
+
+//Error implements the error interface
+func (re *RequirementsError) Error() string {
+	problems := make([]string, 0, len(re.Errors))
+	for _, err := range re.Errors {
+		problems = append(problems, err.Error())
+	}
+
+	return fmt.Sprintf("%d plugin requirements are not satisfied: %s", len(re.Errors), strings.Join(problems, "; "))
+}
+
+//ParseRequirements reads the requirements json file and
+//validates the declared semver constraints
+func ParseRequirements(requirementsFile string) (*spec.Requirements, error) {
+	data, err := ioutil.ReadFile(requirementsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	requirements := &spec.Requirements{}
+	if err := json.Unmarshal(data, requirements); err != nil {
+		return nil, fmt.Errorf("failed to parse requirements file %s: %s", requirementsFile, err)
+	}
+
+	for _, r := range requirements.Plugins {
+		if r == nil || len(r.Name) == 0 {
+			return nil, fmt.Errorf("missing plugin name in requirements file %s", requirementsFile)
+		}
+
+		if _, err := parseConstraint(r.Version); err != nil {
+			return nil, fmt.Errorf("invalid version constraint of plugin '%s' in requirements file %s: %s", r.Name, requirementsFile, err)
+		}
+	}
+
+	return requirements, nil
+}
+
+//parseConstraint parses the semver constraint, the empty one matches any version
+func parseConstraint(constraint string) (*semver.Constraints, error) {
+	if len(strings.TrimSpace(constraint)) == 0 {
+		constraint = anyVersion
+	}
+
+	return semver.NewConstraint(constraint)
+}
+
+//matchVersion picks the highest version satisfying the constraint from
+//the plugin items sorted by the version ascending.
+//If nothing matches, a *NoMatchingVersionError will be returned.
+func matchVersion(name string, items []*spec.PluginItem, constraint string) (*spec.PluginItem, error) {
+	if len(strings.TrimSpace(constraint)) == 0 {
+		constraint = anyVersion
+	}
+
+	c, err := parseConstraint(constraint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version constraint '%s': %s", constraint, err)
+	}
+
+	for i := len(items) - 1; i >= 0; i-- {
+		v, err := semver.NewVersion(items[i].Spec.Version)
+		if err != nil {
+			continue
+		}
+
+		if c.Check(v) {
+			return items[i], nil
+		}
+	}
+
+	available := make([]string, 0, len(items))
+	for _, item := range items {
+		available = append(available, item.Spec.Version)
+	}
+
+	return nil, &NoMatchingVersionError{
+		Plugin:     name,
+		Constraint: constraint,
+		Available:  available,
+	}
+}
+
+//checkRequirements checks the requirements with the plugins in the store.
+//If any requirements are not satisfied, a *RequirementsError will be returned.
+func checkRequirements(store Store, requirements *spec.Requirements) error {
+	if requirements == nil {
+		return errors.New("nil requirements")
+	}
+
+	re := &RequirementsError{}
+	for _, r := range requirements.Plugins {
+		if r == nil {
+			continue
+		}
+
+		if _, err := matchVersion(r.Name, store.Versions(r.Name), r.Version); err != nil {
+			re.Errors = append(re.Errors, err)
+		}
+	}
+
+	if len(re.Errors) > 0 {
+		return re
+	}
+
+	return nil
+}
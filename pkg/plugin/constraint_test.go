@@ -0,0 +1,161 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/steven-zou/go-plugin/pkg/context"
+	"github.com/steven-zou/go-plugin/pkg/spec"
+)
+
+//newTestItem creates a plugin item with a no-op executor
+func newTestItem(name string, version string, deps ...*spec.Requirement) *spec.PluginItem {
+	return &spec.PluginItem{
+		Spec: &spec.Plugin{
+			Name:         name,
+			Version:      version,
+			Dependencies: deps,
+		},
+		Executor: func(ctx context.PluginContext) error { return nil },
+	}
+}
+
+func TestParseRequirements(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		plugins int
+		err     string
+	}{
+		{"valid", `{"plugins": [{"name": "billing", "version": "^1.4, <2.0"}, {"name": "audit"}]}`, 2, ""},
+		{"empty", `{}`, 0, ""},
+		{"invalid json", `{"plugins": [`, 0, "failed to parse requirements file"},
+		{"missing name", `{"plugins": [{"version": "1.0.0"}]}`, 0, "missing plugin name"},
+		{"null requirement", `{"plugins": [null]}`, 0, "missing plugin name"},
+		{"invalid constraint", `{"plugins": [{"name": "billing", "version": "~>x.y"}]}`, 0, "invalid version constraint of plugin 'billing'"},
+	}
+
+	dir, err := ioutil.TempDir("", "requirements-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for i, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			file := filepath.Join(dir, fmt.Sprintf("requirements-%d.json", i))
+			if err := ioutil.WriteFile(file, []byte(c.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			requirements, err := ParseRequirements(file)
+			if len(c.err) > 0 {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("expect error containing %q but got %v", c.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if len(requirements.Plugins) != c.plugins {
+				t.Fatalf("expect %d plugins but got %d", c.plugins, len(requirements.Plugins))
+			}
+		})
+	}
+
+	if _, err := ParseRequirements(filepath.Join(dir, "not-existing.json")); err == nil {
+		t.Fatal("expect error for the not existing file")
+	}
+}
+
+func TestMatchVersion(t *testing.T) {
+	items := []*spec.PluginItem{
+		newTestItem("billing", "1.2.0"),
+		newTestItem("billing", "1.4.2"),
+		newTestItem("billing", "2.0.0"),
+		newTestItem("billing", "2.1.0-rc.1"),
+	}
+
+	cases := []struct {
+		constraint string
+		expected   string
+	}{
+		{"", "2.0.0"},
+		{"*", "2.0.0"},
+		{"^1.2", "1.4.2"},
+		{"~1.2", "1.2.0"},
+		{">=1.3, <2.0", "1.4.2"},
+		{"1.2.0", "1.2.0"},
+		{">=2.1.0-rc.0", "2.1.0-rc.1"},
+		{"^3", ""},
+		{"<1.0", ""},
+	}
+
+	for _, c := range cases {
+		item, err := matchVersion("billing", items, c.constraint)
+		if len(c.expected) == 0 {
+			var nmv *NoMatchingVersionError
+			if !errors.As(err, &nmv) {
+				t.Errorf("constraint %q: expect *NoMatchingVersionError but got %v", c.constraint, err)
+				continue
+			}
+			if len(nmv.Available) != len(items) {
+				t.Errorf("constraint %q: expect %d available versions but got %v", c.constraint, len(items), nmv.Available)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("constraint %q: unexpected error: %s", c.constraint, err)
+			continue
+		}
+		if item.Spec.Version != c.expected {
+			t.Errorf("constraint %q: expect version %s but got %s", c.constraint, c.expected, item.Spec.Version)
+		}
+	}
+
+	if _, err := matchVersion("billing", items, "~>x.y"); err == nil {
+		t.Error("expect error for the invalid constraint")
+	}
+}
+
+func TestCheckRequirements(t *testing.T) {
+	store := NewBaseStore()
+	store.Put(newTestItem("billing", "1.4.2"), false)
+	store.Put(newTestItem("audit", "0.3.0"), false)
+
+	err := checkRequirements(store, &spec.Requirements{
+		Plugins: []*spec.Requirement{
+			{Name: "billing", Version: "^1.4"},
+			{Name: "audit"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	err = checkRequirements(store, &spec.Requirements{
+		Plugins: []*spec.Requirement{
+			{Name: "billing", Version: "^2"},
+			{Name: "audit", Version: "<1.0"},
+			{Name: "reporting"},
+		},
+	})
+	var re *RequirementsError
+	if !errors.As(err, &re) {
+		t.Fatalf("expect *RequirementsError but got %v", err)
+	}
+	if len(re.Errors) != 2 {
+		t.Fatalf("expect 2 unsatisfied requirements but got %d: %s", len(re.Errors), err)
+	}
+
+	if err := checkRequirements(store, nil); err == nil {
+		t.Fatal("expect error for nil requirements")
+	}
+}
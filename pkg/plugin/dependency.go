@@ -0,0 +1,221 @@
+package plugin
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/steven-zou/go-plugin/pkg/spec"
+)
+
+//DependencyError is returned when the dependency of the plugin is not satisfied
+type DependencyError struct {
+	//Name of the plugin
+	Plugin string
+
+	//Version of the plugin
+	Version string
+
+	//The unsatisfied dependency
+	Dependency *spec.Requirement
+
+	//The underlying error
+	Err error
+}
+
+//Error implements the error interface
+func (de *DependencyError) Error() string {
+	return fmt.Sprintf("dependency '%s' of plugin %s:%s is not satisfied: %s", dependencyRef(de.Dependency), de.Plugin, de.Version, de.Err)
+}
+
+//DependencyCycleError is returned when the plugin is in or depends on a dependency cycle
+type DependencyCycleError struct {
+	//Name of the plugin
+	Plugin string
+
+	//The plugins in the cycle with 'name:version' format
+	Cycle []string
+}
+
+//Error implements the error interface
+func (dce *DependencyCycleError) Error() string {
+	return fmt.Sprintf("plugin %s is in or depends on a dependency cycle: %s", dce.Plugin, strings.Join(dce.Cycle, " -> "))
+}
+
+//DependentsError is returned when unloading the plugin which other loaded plugins still depend on
+type DependentsError struct {
+	//Name of the plugin
+	Plugin string
+
+	//Version of the plugin
+	Version string
+
+	//The dependent plugins with 'name:version' format
+	Dependents []string
+}
+
+//Error implements the error interface
+func (de *DependentsError) Error() string {
+	return fmt.Sprintf("plugin %s:%s is still depended on by [%s], unload them first or unload with cascade", de.Plugin, de.Version, strings.Join(de.Dependents, ", "))
+}
+
+//dependencyRef formats the dependency as 'name@constraint' for messages
+func dependencyRef(dep *spec.Requirement) string {
+	if len(strings.TrimSpace(dep.Version)) == 0 {
+		return dep.Name
+	}
+
+	return fmt.Sprintf("%s@%s", dep.Name, dep.Version)
+}
+
+//satisfies checks if the plugin satisfies the dependency
+func satisfies(plugin *spec.Plugin, dep *spec.Requirement) bool {
+	if plugin.Name != dep.Name {
+		return false
+	}
+
+	c, err := parseConstraint(dep.Version)
+	if err != nil {
+		return false
+	}
+
+	v, err := semver.NewVersion(plugin.Version)
+	if err != nil {
+		return false
+	}
+
+	return c.Check(v)
+}
+
+//checkDependencies checks the dependencies of the plugin are loaded in the store.
+//If any dependency is not satisfied, a *DependencyError will be returned.
+func checkDependencies(store Store, plugin *spec.Plugin) error {
+	for _, dep := range plugin.Dependencies {
+		if _, err := matchVersion(dep.Name, store.Versions(dep.Name), dep.Version); err != nil {
+			return &DependencyError{
+				Plugin:     plugin.Name,
+				Version:    plugin.Version,
+				Dependency: dep,
+				Err:        err,
+			}
+		}
+	}
+
+	return nil
+}
+
+//dependents returns the loaded plugins which depend on the plugin item and
+//can not be satisfied by the other loaded versions once the item is removed
+func dependents(store Store, item *spec.PluginItem) []*spec.PluginItem {
+	found := make([]*spec.PluginItem, 0)
+	for _, other := range store.List() {
+		for _, dep := range other.Spec.Dependencies {
+			if !satisfies(item.Spec, dep) {
+				continue
+			}
+
+			//Check if any other version can satisfy it
+			satisfied := false
+			for _, v := range store.Versions(dep.Name) {
+				if v != item && satisfies(v.Spec, dep) {
+					satisfied = true
+					break
+				}
+			}
+
+			if !satisfied {
+				found = append(found, other)
+				break
+			}
+		}
+	}
+
+	return found
+}
+
+//sortByDependencies sorts the plugins in the topological order of the dependencies,
+//the plugins keep the original order if no dependencies between them.
+//The plugins in or depending on the cycles are returned with the cycle errors.
+func sortByDependencies(plugins []*spec.Plugin) ([]*spec.Plugin, map[*spec.Plugin]error) {
+	//deps[i] are the indexes of the plugins which plugins[i] depends on.
+	//All the versions of the dependency are loaded first, so the constraint
+	//is checked with all of them.
+	deps := make([][]int, len(plugins))
+	//inDegrees[i] is the count of the unsorted dependencies of plugins[i]
+	inDegrees := make([]int, len(plugins))
+	for i, p := range plugins {
+		for _, dep := range p.Dependencies {
+			for j, other := range plugins {
+				if i != j && other.Name == dep.Name {
+					deps[i] = append(deps[i], j)
+					inDegrees[i]++
+				}
+			}
+		}
+	}
+
+	sorted := make([]*spec.Plugin, 0, len(plugins))
+	done := make([]bool, len(plugins))
+	for len(sorted) < len(plugins) {
+		//Pick the first one without unsorted dependencies
+		next := -1
+		for i := range plugins {
+			if !done[i] && inDegrees[i] == 0 {
+				next = i
+				break
+			}
+		}
+
+		if next < 0 {
+			//Only the cyclic ones are left
+			break
+		}
+
+		done[next] = true
+		sorted = append(sorted, plugins[next])
+		for i := range plugins {
+			for _, j := range deps[i] {
+				if j == next {
+					inDegrees[i]--
+				}
+			}
+		}
+	}
+
+	cyclic := make(map[*spec.Plugin]error)
+	for i, p := range plugins {
+		if !done[i] {
+			cyclic[p] = &DependencyCycleError{
+				Plugin: p.Name,
+				Cycle:  findCycle(plugins, deps, done, i),
+			}
+		}
+	}
+
+	return sorted, cyclic
+}
+
+//findCycle walks the unsorted dependencies from the start plugin until a plugin is visited again.
+//Each unsorted plugin has at least one unsorted dependency, so the walk always ends with a cycle.
+func findCycle(plugins []*spec.Plugin, deps [][]int, done []bool, start int) []string {
+	visited := make(map[int]int)
+	path := make([]int, 0)
+	for i := start; ; {
+		if at, ok := visited[i]; ok {
+			cycle := make([]string, 0, len(path)-at+1)
+			for _, j := range append(path[at:], i) {
+				cycle = append(cycle, fmt.Sprintf("%s:%s", plugins[j].Name, plugins[j].Version))
+			}
+			return cycle
+		}
+
+		visited[i] = len(path)
+		path = append(path, i)
+		for _, j := range deps[i] {
+			if !done[j] {
+				i = j
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/steven-zou/go-plugin/pkg/spec"
+)
+
+//newTestPlugin creates a plugin spec depending on the plugins with the 'name' or 'name@constraint' refs
+func newTestPlugin(name string, version string, deps ...string) *spec.Plugin {
+	plugin := &spec.Plugin{Name: name, Version: version}
+	for _, dep := range deps {
+		parts := strings.SplitN(dep, "@", 2)
+		r := &spec.Requirement{Name: parts[0]}
+		if len(parts) > 1 {
+			r.Version = parts[1]
+		}
+		plugin.Dependencies = append(plugin.Dependencies, r)
+	}
+
+	return plugin
+}
+
+//refs formats the plugins as 'name:version' refs
+func refs(plugins []*spec.Plugin) string {
+	names := make([]string, 0, len(plugins))
+	for _, p := range plugins {
+		names = append(names, fmt.Sprintf("%s:%s", p.Name, p.Version))
+	}
+
+	return strings.Join(names, " ")
+}
+
+func TestSortByDependencies(t *testing.T) {
+	cases := []struct {
+		name     string
+		plugins  []*spec.Plugin
+		expected string
+		cyclic   []string
+	}{
+		{
+			name: "no dependencies keeps order",
+			plugins: []*spec.Plugin{
+				newTestPlugin("c", "1.0.0"),
+				newTestPlugin("a", "1.0.0"),
+				newTestPlugin("b", "1.0.0"),
+			},
+			expected: "c:1.0.0 a:1.0.0 b:1.0.0",
+		},
+		{
+			name: "chain",
+			plugins: []*spec.Plugin{
+				newTestPlugin("app", "1.0.0", "billing"),
+				newTestPlugin("billing", "1.0.0", "audit"),
+				newTestPlugin("audit", "1.0.0"),
+			},
+			expected: "audit:1.0.0 billing:1.0.0 app:1.0.0",
+		},
+		{
+			name: "diamond",
+			plugins: []*spec.Plugin{
+				newTestPlugin("app", "1.0.0", "billing", "reporting"),
+				newTestPlugin("reporting", "1.0.0", "audit"),
+				newTestPlugin("billing", "1.0.0", "audit"),
+				newTestPlugin("audit", "1.0.0"),
+			},
+			expected: "audit:1.0.0 reporting:1.0.0 billing:1.0.0 app:1.0.0",
+		},
+		{
+			name: "all versions of dependency first",
+			plugins: []*spec.Plugin{
+				newTestPlugin("app", "1.0.0", "billing@^2"),
+				newTestPlugin("billing", "2.0.0"),
+				newTestPlugin("billing", "1.0.0"),
+			},
+			expected: "billing:2.0.0 billing:1.0.0 app:1.0.0",
+		},
+		{
+			name: "missing dependency does not block",
+			plugins: []*spec.Plugin{
+				newTestPlugin("app", "1.0.0", "missing"),
+				newTestPlugin("audit", "1.0.0"),
+			},
+			expected: "app:1.0.0 audit:1.0.0",
+		},
+		{
+			name: "cycle",
+			plugins: []*spec.Plugin{
+				newTestPlugin("a", "1.0.0", "b"),
+				newTestPlugin("b", "1.0.0", "c"),
+				newTestPlugin("c", "1.0.0", "a"),
+				newTestPlugin("d", "1.0.0"),
+			},
+			expected: "d:1.0.0",
+			cyclic:   []string{"a", "b", "c"},
+		},
+		{
+			name: "depending on cycle",
+			plugins: []*spec.Plugin{
+				newTestPlugin("app", "1.0.0", "a"),
+				newTestPlugin("a", "1.0.0", "b"),
+				newTestPlugin("b", "1.0.0", "a"),
+				newTestPlugin("audit", "1.0.0"),
+				newTestPlugin("billing", "1.0.0", "audit"),
+			},
+			expected: "audit:1.0.0 billing:1.0.0",
+			cyclic:   []string{"app", "a", "b"},
+		},
+		{
+			name: "self dependency is ignored",
+			plugins: []*spec.Plugin{
+				newTestPlugin("a", "1.0.0", "a"),
+			},
+			expected: "a:1.0.0",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sorted, cyclic := sortByDependencies(c.plugins)
+			if got := refs(sorted); got != c.expected {
+				t.Errorf("expect sorted [%s] but got [%s]", c.expected, got)
+			}
+
+			if len(cyclic) != len(c.cyclic) {
+				t.Fatalf("expect %d cyclic plugins but got %d", len(c.cyclic), len(cyclic))
+			}
+			for _, p := range c.plugins {
+				err, ok := cyclic[p]
+				if !ok {
+					continue
+				}
+
+				var dce *DependencyCycleError
+				if !errors.As(err, &dce) {
+					t.Fatalf("expect *DependencyCycleError for %s but got %v", p.Name, err)
+				}
+				if dce.Plugin != p.Name {
+					t.Errorf("expect cycle error of %s but got %s", p.Name, dce.Plugin)
+				}
+				//The cycle is closed with the first plugin in it
+				if n := len(dce.Cycle); n < 3 || dce.Cycle[0] != dce.Cycle[n-1] {
+					t.Errorf("expect a closed cycle for %s but got %v", p.Name, dce.Cycle)
+				}
+			}
+			for _, name := range c.cyclic {
+				found := false
+				for p := range cyclic {
+					found = found || p.Name == name
+				}
+				if !found {
+					t.Errorf("expect %s to be reported with the cycle", name)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckDependencies(t *testing.T) {
+	store := NewBaseStore()
+	store.Put(newTestItem("audit", "1.2.0"), false)
+
+	if err := checkDependencies(store, newTestPlugin("billing", "1.0.0", "audit@^1.0")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	cases := []struct {
+		plugin     *spec.Plugin
+		dependency string
+	}{
+		{newTestPlugin("billing", "1.0.0", "audit@^2.0"), "audit"},
+		{newTestPlugin("billing", "1.0.0", "audit", "missing"), "missing"},
+	}
+	for _, c := range cases {
+		err := checkDependencies(store, c.plugin)
+
+		var de *DependencyError
+		if !errors.As(err, &de) {
+			t.Errorf("expect *DependencyError but got %v", err)
+			continue
+		}
+		if de.Dependency.Name != c.dependency {
+			t.Errorf("expect unsatisfied dependency %s but got %s", c.dependency, de.Dependency.Name)
+		}
+
+		var nmv *NoMatchingVersionError
+		if !errors.As(de.Err, &nmv) {
+			t.Errorf("expect *NoMatchingVersionError but got %v", de.Err)
+		}
+	}
+}
+
+func TestDependents(t *testing.T) {
+	store := NewBaseStore()
+	audit1 := newTestItem("audit", "1.2.0")
+	audit2 := newTestItem("audit", "2.0.0")
+	store.Put(audit1, false)
+	store.Put(audit2, false)
+	store.Put(newTestItem("billing", "1.0.0", &spec.Requirement{Name: "audit", Version: "^1.0"}), false)
+	store.Put(newTestItem("reporting", "1.0.0", &spec.Requirement{Name: "audit"}), false)
+
+	//Only billing can not be satisfied by audit 2.0.0
+	found := dependents(store, audit1)
+	if len(found) != 1 || found[0].Spec.Name != "billing" {
+		t.Fatalf("expect [billing] depending on audit 1.2.0 but got %d dependents", len(found))
+	}
+
+	//Reporting can be satisfied by audit 1.2.0
+	if found := dependents(store, audit2); len(found) != 0 {
+		t.Fatalf("expect no dependents of audit 2.0.0 but got %d", len(found))
+	}
+}
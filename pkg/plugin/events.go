@@ -0,0 +1,158 @@
+package plugin
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/steven-zou/go-plugin/pkg"
+	"github.com/steven-zou/go-plugin/pkg/context"
+	"github.com/steven-zou/go-plugin/pkg/spec"
+)
+
+//DefaultEventBuffer is the default buffer size of the event subscription
+const DefaultEventBuffer = 256
+
+//EventType is the type of the plugin state change
+type EventType string
+
+//The types of the events
+const (
+	//EventDiscovered is sent when the plugin dir or bundle is found in the plugin base dir
+	EventDiscovered EventType = "discovered"
+
+	//EventValidated is sent when the plugin passes the validation
+	EventValidated EventType = "validated"
+
+	//EventValidationFailed is sent when the plugin fails the validation
+	EventValidationFailed EventType = "validation-failed"
+
+	//EventLoaded is sent when the plugin is loaded, initialized and saved to the store
+	EventLoaded EventType = "loaded"
+
+	//EventLoadFailed is sent when the plugin fails to load, including the unsatisfied dependencies
+	EventLoadFailed EventType = "load-failed"
+
+	//EventUnloaded is sent when the plugin is unloaded
+	EventUnloaded EventType = "unloaded"
+
+	//EventUpgraded is sent when the plugin upgrading is done, the error is set if failed or rolled back
+	EventUpgraded EventType = "upgraded"
+
+	//EventExecuted is sent when the plugin entry is executed
+	EventExecuted EventType = "executed"
+
+	//EventRetrying is sent when the failed execution is going to be retried,
+	//the duration is the backoff before the next attempt
+	EventRetrying EventType = "retrying"
+
+	//EventCircuitOpened is sent when the circuit breaker of the plugin opens
+	EventCircuitOpened EventType = "circuit-opened"
+
+	//EventCircuitHalfOpen is sent when the circuit breaker of the plugin starts the trial executions
+	EventCircuitHalfOpen EventType = "circuit-half-open"
+
+	//EventCircuitClosed is sent when the circuit breaker of the plugin closes after the trial executions
+	EventCircuitClosed EventType = "circuit-closed"
+
+	//EventQuarantined is sent when the plugin is quarantined, the error carries the reason
+	EventQuarantined EventType = "quarantined"
+
+	//EventEnabled is sent when the quarantined plugin is enabled again
+	EventEnabled EventType = "enabled"
+)
+
+//Event reports the state change of the plugin
+type Event struct {
+	//The type of the event
+	Type EventType
+
+	//Name of the plugin
+	Name string
+
+	//Version of the plugin, empty if it's unknown
+	Version string
+
+	//The path of the plugin dir or bundle, empty if it's unknown
+	Path string
+
+	//When the event happens
+	Time time.Time
+
+	//The duration of the execution, only set for the 'executed' event
+	Duration time.Duration
+
+	//The ID of the execution started with 'Execute', only set for the 'executed' and 'retrying' events
+	ExecutionID string
+
+	//The attempt number (starting from 1) of the execution with the retry policy,
+	//only set for the 'executed' and 'retrying' events
+	Attempt int
+
+	//The error of the event, nil if succeeded
+	Err error
+}
+
+//Subscribe implements the interface method
+func (bm *BaseManager) Subscribe(buffer int, policy DropPolicy) (<-chan *Event, func()) {
+	if buffer <= 0 {
+		buffer = DefaultEventBuffer
+	}
+
+	return bm.events.subscribe(buffer, policy)
+}
+
+//emit the event of the plugin and count it in the metrics
+func (bm *BaseManager) emit(eventType EventType, plugin *spec.Plugin, err error) {
+	bm.metrics.count(eventType, plugin, err)
+	bm.events.publish(&Event{
+		Type:    eventType,
+		Name:    plugin.Name,
+		Version: plugin.Version,
+		Path:    plugin.Path,
+		Time:    time.Now(),
+		Err:     err,
+	})
+}
+
+//emitPath emits the event of the plugin dir or bundle without a valid spec,
+//the name and version are got from the dir name
+func (bm *BaseManager) emitPath(eventType EventType, pluginPath string, err error) {
+	name, version := pkg.SplitPluginDirName(filepath.Base(pluginPath))
+	bm.events.publish(&Event{
+		Type:    eventType,
+		Name:    name,
+		Version: version,
+		Path:    pluginPath,
+		Time:    time.Now(),
+		Err:     err,
+	})
+}
+
+//observe wraps the executor to emit the 'executed' events
+func (bm *BaseManager) observe(plugin *spec.Plugin, exec spec.PluginExecutor) spec.PluginExecutor {
+	return func(ctx context.PluginContext) error {
+		start := time.Now()
+		err := exec(ctx)
+		end := time.Now()
+
+		bm.events.publish(&Event{
+			Type:        EventExecuted,
+			Name:        plugin.Name,
+			Version:     plugin.Version,
+			Path:        plugin.Path,
+			Time:        end,
+			Duration:    end.Sub(start),
+			ExecutionID: stringValue(ctx, context.ExecutionIDKey),
+			Attempt:     intValue(ctx, context.AttemptKey),
+			Err:         err,
+		})
+
+		return err
+	}
+}
+
+//intValue gets the int value from the plugin context, zero if it's not an int
+func intValue(ctx context.ValueContext, key string) int {
+	i, _ := ctx.GetValue(key).(int)
+	return i
+}
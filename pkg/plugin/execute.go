@@ -0,0 +1,188 @@
+package plugin
+
+import (
+	sys_context "context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/steven-zou/go-plugin/pkg/context"
+	"github.com/steven-zou/go-plugin/pkg/spec"
+)
+
+//ExecuteOptions are the options of executing the plugin with the manager
+type ExecuteOptions struct {
+	//The version of the plugin, empty or 'latest' means the latest version
+	Version string
+
+	//The label of the entry, empty means the default executor
+	Label string
+
+	//The timeout of the execution, overrides the default one in the 'plugin.json'.
+	//Zero means using the default one.
+	Timeout time.Duration
+
+	//The ID of the execution for correlation, generated if empty
+	ID string
+}
+
+//ExecutionResult is the result of executing the plugin with the manager
+type ExecutionResult struct {
+	//The ID of the execution
+	ID string
+
+	//Name of the plugin
+	Plugin string
+
+	//Version of the plugin
+	Version string
+
+	//The values of the plugin context after the execution,
+	//nil if the execution does not finish in time
+	Values map[string]interface{}
+
+	//The duration of the execution
+	Duration time.Duration
+}
+
+//PanicError is returned when the plugin panics in the execution
+type PanicError struct {
+	//Name of the plugin
+	Plugin string
+
+	//Version of the plugin
+	Version string
+
+	//The ID of the execution
+	ExecutionID string
+
+	//The value passed to panic
+	Value interface{}
+
+	//The stack trace of the panicking goroutine
+	Stack []byte
+}
+
+//Error implements the error interface
+func (pe *PanicError) Error() string {
+	return fmt.Sprintf("plugin %s:%s panicked in execution %s: %v", pe.Plugin, pe.Version, pe.ExecutionID, pe.Value)
+}
+
+//TimeoutError is returned when the execution does not finish in the timeout
+type TimeoutError struct {
+	//Name of the plugin
+	Plugin string
+
+	//Version of the plugin
+	Version string
+
+	//The ID of the execution
+	ExecutionID string
+
+	//The timeout of the execution
+	Timeout time.Duration
+}
+
+//Error implements the error interface
+func (te *TimeoutError) Error() string {
+	return fmt.Sprintf("execution %s of plugin %s:%s timeout after %s", te.ExecutionID, te.Plugin, te.Version, te.Timeout)
+}
+
+//Execute implements the interface method
+func (bm *BaseManager) Execute(ctx sys_context.Context, name string, values map[string]interface{}, opts *ExecuteOptions) (*ExecutionResult, error) {
+	if len(name) == 0 {
+		return nil, errors.New("plugin name cannot be empty")
+	}
+	if ctx == nil {
+		ctx = sys_context.Background()
+	}
+	if opts == nil {
+		opts = &ExecuteOptions{}
+	}
+
+	pluginItem, ok := bm.store.GetVersion(name, opts.Version)
+	if !ok {
+		return nil, fmt.Errorf("plugin '%s' is not existing", pluginRef(name, opts.Version))
+	}
+
+	exec := pluginItem.Executor
+	if len(opts.Label) > 0 {
+		if exec, ok = pluginItem.Entries[opts.Label]; !ok {
+			return nil, fmt.Errorf("entry with label '%s' is not existing in plugin '%s'", opts.Label, name)
+		}
+	}
+
+	result := &ExecutionResult{
+		ID:      opts.ID,
+		Plugin:  pluginItem.Spec.Name,
+		Version: pluginItem.Spec.Version,
+	}
+	if len(result.ID) == 0 {
+		result.ID = newID(16)
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = executionTimeout(pluginItem.Spec)
+	}
+	if timeout > 0 {
+		var cancel sys_context.CancelFunc
+		ctx, cancel = sys_context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	pc := context.New(ctx, values)
+	pc.SetValue(context.ExecutionIDKey, result.ID)
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- &PanicError{
+					Plugin:      result.Plugin,
+					Version:     result.Version,
+					ExecutionID: result.ID,
+					Value:       r,
+					Stack:       debug.Stack(),
+				}
+			}
+		}()
+
+		done <- exec(pc)
+	}()
+
+	select {
+	case err := <-done:
+		result.Duration = time.Since(start)
+		result.Values = context.Values(pc)
+		return result, err
+	case <-ctx.Done():
+		//The plugin may still be running, its values are not read
+		result.Duration = time.Since(start)
+		if errors.Is(ctx.Err(), sys_context.DeadlineExceeded) && timeout > 0 {
+			return result, &TimeoutError{
+				Plugin:      result.Plugin,
+				Version:     result.Version,
+				ExecutionID: result.ID,
+				Timeout:     timeout,
+			}
+		}
+		return result, ctx.Err()
+	}
+}
+
+//executionTimeout returns the default execution timeout in the plugin spec, zero if not set
+func executionTimeout(pluginSpec *spec.Plugin) time.Duration {
+	if len(pluginSpec.Timeout) == 0 {
+		return 0
+	}
+
+	timeout, err := time.ParseDuration(pluginSpec.Timeout)
+	if err != nil || timeout < 0 {
+		return 0
+	}
+
+	return timeout
+}
@@ -0,0 +1,108 @@
+package plugin
+
+import (
+	sys_context "context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+//executeSource is the interpreted plugin source whose 'Execute' panics
+//with the 'panic' value if it's not empty, otherwise blocks until the context is done
+const executeSource = `package main
+
+import "github.com/steven-zou/go-plugin/pkg/context"
+
+func Execute(ctx context.PluginContext) error {
+	if v := ctx.GetValue("panic"); v != nil {
+		panic(v)
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+`
+
+func TestExecutePanic(t *testing.T) {
+	baseDir, err := ioutil.TempDir("", "execute-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(baseDir)
+
+	writePlugin(t, baseDir, "billing", "1.0.0", "", executeSource)
+	bm := loadPlugins(t, baseDir)
+
+	result, err := bm.Execute(nil, "billing", map[string]interface{}{"panic": "boom"}, &ExecuteOptions{ID: "exec-1"})
+	var pe *PanicError
+	if !errors.As(err, &pe) {
+		t.Fatalf("expect *PanicError but got %v", err)
+	}
+	if pe.Plugin != "billing" || pe.Version != "1.0.0" || pe.ExecutionID != "exec-1" {
+		t.Fatalf("expect panic of billing:1.0.0 in exec-1 but got %s", pe)
+	}
+	if fmt.Sprint(pe.Value) != "boom" || len(pe.Stack) == 0 {
+		t.Fatalf("expect panic value boom with the stack but got %v and %d bytes", pe.Value, len(pe.Stack))
+	}
+	if result == nil || result.ID != "exec-1" {
+		t.Fatalf("expect result of exec-1 but got %+v", result)
+	}
+}
+
+func TestExecuteTimeout(t *testing.T) {
+	baseDir, err := ioutil.TempDir("", "execute-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(baseDir)
+
+	writePlugin(t, baseDir, "billing", "1.0.0", `, "timeout": "50ms"`, executeSource)
+	writePlugin(t, baseDir, "shipping", "1.0.0", "", executeSource)
+	bm := loadPlugins(t, baseDir)
+
+	cases := []struct {
+		name     string
+		plugin   string
+		timeout  time.Duration
+		cancel   time.Duration
+		expected time.Duration
+		err      error
+	}{
+		{name: "timeout in plugin.json", plugin: "billing", expected: 50 * time.Millisecond},
+		{name: "timeout in options", plugin: "billing", timeout: 20 * time.Millisecond, expected: 20 * time.Millisecond},
+		{name: "no timeout", plugin: "shipping", timeout: 0, cancel: 20 * time.Millisecond, err: sys_context.Canceled},
+		{name: "canceled before timeout", plugin: "billing", timeout: time.Second, cancel: 20 * time.Millisecond, err: sys_context.Canceled},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx, cancel := sys_context.WithCancel(sys_context.Background())
+			defer cancel()
+			if c.cancel > 0 {
+				time.AfterFunc(c.cancel, cancel)
+			}
+
+			result, err := bm.Execute(ctx, c.plugin, nil, &ExecuteOptions{ID: "exec-1", Timeout: c.timeout})
+			if c.err != nil {
+				var te *TimeoutError
+				if !errors.Is(err, c.err) || errors.As(err, &te) {
+					t.Fatalf("expect %v but got %v", c.err, err)
+				}
+				return
+			}
+
+			var te *TimeoutError
+			if !errors.As(err, &te) {
+				t.Fatalf("expect *TimeoutError but got %v", err)
+			}
+			if te.Plugin != c.plugin || te.ExecutionID != "exec-1" || te.Timeout != c.expected {
+				t.Fatalf("expect timeout %s of %s in exec-1 but got %s", c.expected, c.plugin, te)
+			}
+			if result.Values != nil || result.Duration < c.expected {
+				t.Fatalf("expect no values and duration not less than %s but got %v and %s", c.expected, result.Values, result.Duration)
+			}
+		})
+	}
+}
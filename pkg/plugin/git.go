@@ -0,0 +1,98 @@
+package plugin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/steven-zou/go-plugin/pkg"
+	"github.com/steven-zou/go-plugin/pkg/spec"
+)
+
+//GitFetcher fetches the plugin source from the git repository
+type GitFetcher struct {
+	//The dir where the repositories are cloned to
+	workDir string
+}
+
+//NewGitFetcher is constructor of GitFetcher
+func NewGitFetcher(workDir string) *GitFetcher {
+	return &GitFetcher{
+		workDir: workDir,
+	}
+}
+
+//Fetch clones the git repository specified by the plugin source path
+//and checks out the pinned ref. The repository is cloned into the dir keyed by
+//the plugin name and the repository and ref, so the plugins pinned to different
+//refs do not share the clone. The existing clone is reused and updated.
+//Return the dir of the checked out source.
+func (gf *GitFetcher) Fetch(plugin *spec.Plugin) (string, error) {
+	if plugin == nil || plugin.Source == nil {
+		return "", errors.New("nil plugin source")
+	}
+
+	if len(plugin.Source.Ref) == 0 {
+		return "", fmt.Errorf("git ref of plugin %s is not set", plugin.Name)
+	}
+
+	if strings.HasPrefix(plugin.Source.Ref, "-") {
+		return "", fmt.Errorf("invalid git ref %s of plugin %s", plugin.Source.Ref, plugin.Name)
+	}
+
+	if err := os.MkdirAll(gf.workDir, 0755); err != nil {
+		return "", err
+	}
+
+	repoDir := filepath.Join(gf.workDir, cloneDirName(plugin))
+	if pkg.IsDir(filepath.Join(repoDir, ".git")) {
+		if err := runCommand(plugin.Name, BuildStageClone, repoDir, "git", "remote", "set-url", "--", "origin", plugin.Source.Path); err != nil {
+			return "", err
+		}
+		if err := runCommand(plugin.Name, BuildStageClone, repoDir, "git", "fetch", "--quiet", "--tags", "--force", "--", "origin"); err != nil {
+			return "", err
+		}
+	} else {
+		if err := os.RemoveAll(repoDir); err != nil {
+			return "", err
+		}
+		if err := runCommand(plugin.Name, BuildStageClone, gf.workDir, "git", "clone", "--quiet", "--no-checkout", "--", plugin.Source.Path, repoDir); err != nil {
+			return "", err
+		}
+	}
+
+	//Prefer the remote branch to the stale local one
+	commit, err := commandOutput(plugin.Name, BuildStageCheckout, repoDir, "git", "rev-parse", "--verify", "--quiet", fmt.Sprintf("origin/%s^{commit}", plugin.Source.Ref))
+	if err != nil {
+		commit, err = commandOutput(plugin.Name, BuildStageCheckout, repoDir, "git", "rev-parse", "--verify", "--quiet", fmt.Sprintf("%s^{commit}", plugin.Source.Ref))
+		if err != nil {
+			if be, ok := err.(*BuildError); ok && len(be.Output) == 0 {
+				be.Output = fmt.Sprintf("ref '%s' is not found", plugin.Source.Ref)
+			}
+			return "", err
+		}
+	}
+
+	if err := runCommand(plugin.Name, BuildStageCheckout, repoDir, "git", "checkout", "--quiet", "--force", "--detach", commit, "--"); err != nil {
+		return "", err
+	}
+
+	//Drop the leftovers of the previous builds
+	if err := runCommand(plugin.Name, BuildStageCheckout, repoDir, "git", "clean", "--quiet", "-ffdx"); err != nil {
+		return "", err
+	}
+
+	return repoDir, nil
+}
+
+//cloneDirName returns the name of the clone dir of the plugin,
+//e.g: 'billing@<digest of the repository and ref>'
+func cloneDirName(plugin *spec.Plugin) string {
+	sum := sha256.Sum256([]byte(plugin.Source.Path + "\n" + plugin.Source.Ref))
+
+	return fmt.Sprintf("%s@%s", plugin.Name, hex.EncodeToString(sum[:])[:16])
+}
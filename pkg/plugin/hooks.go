@@ -0,0 +1,238 @@
+package plugin
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/steven-zou/go-plugin/pkg/context"
+	"github.com/steven-zou/go-plugin/pkg/spec"
+)
+
+//PluginHook is called with the plugin spec before loading or unloading the plugin.
+//If an error is returned, the operation is vetoed.
+type PluginHook func(plugin *spec.Plugin) error
+
+//LoadedHook is called with the plugin spec after the plugin is loaded and initialized
+type LoadedHook func(plugin *spec.Plugin)
+
+//LoadFailedHook is called with the plugin spec and the error when failed to load the plugin
+type LoadFailedHook func(plugin *spec.Plugin, err error)
+
+//BeforeExecuteHook is called with the plugin spec and the plugin context before
+//executing the plugin entry. If an error is returned, the execution is vetoed
+//and the error is returned to the caller.
+type BeforeExecuteHook func(plugin *spec.Plugin, ctx context.PluginContext) error
+
+//AfterExecuteHook is called with the plugin spec, the plugin context, the duration
+//and the returned error after executing the plugin entry
+type AfterExecuteHook func(plugin *spec.Plugin, ctx context.PluginContext, duration time.Duration, err error)
+
+//HookVetoError is returned when the operation is vetoed by a hook
+type HookVetoError struct {
+	//The hook vetoing the operation
+	Hook string
+
+	//Name of the plugin
+	Plugin string
+
+	//Version of the plugin
+	Version string
+
+	//The error returned by the hook
+	Err error
+}
+
+//Error implements the error interface
+func (hve *HookVetoError) Error() string {
+	return fmt.Sprintf("%s hook vetoed plugin %s:%s: %s", hve.Hook, hve.Plugin, hve.Version, hve.Err)
+}
+
+//The names of the hooks
+const (
+	hookBeforeLoad    = "BeforeLoad"
+	hookBeforeUnload  = "BeforeUnload"
+	hookBeforeExecute = "BeforeExecute"
+)
+
+//HookRegistry keeps the host callbacks of the plugin lifecycle.
+//The hooks are called in the registration order.
+type HookRegistry struct {
+	//internal lock
+	lock *sync.RWMutex
+
+	beforeLoad    []PluginHook
+	afterLoad     []LoadedHook
+	loadFailed    []LoadFailedHook
+	beforeUnload  []PluginHook
+	beforeExecute []BeforeExecuteHook
+	afterExecute  []AfterExecuteHook
+}
+
+//NewHookRegistry is constructor of HookRegistry
+func NewHookRegistry() *HookRegistry {
+	return &HookRegistry{
+		lock: new(sync.RWMutex),
+	}
+}
+
+//BeforeLoad registers the hook called before loading the plugin,
+//including loading the new version when upgrading
+func (hr *HookRegistry) BeforeLoad(hook PluginHook) {
+	if hook == nil {
+		return
+	}
+
+	hr.lock.Lock()
+	defer hr.lock.Unlock()
+
+	hr.beforeLoad = append(hr.beforeLoad, hook)
+}
+
+//AfterLoad registers the hook called after the plugin is loaded and initialized
+func (hr *HookRegistry) AfterLoad(hook LoadedHook) {
+	if hook == nil {
+		return
+	}
+
+	hr.lock.Lock()
+	defer hr.lock.Unlock()
+
+	hr.afterLoad = append(hr.afterLoad, hook)
+}
+
+//LoadFailed registers the hook called when failed to load or initialize the plugin,
+//including being vetoed by the 'BeforeLoad' hooks
+func (hr *HookRegistry) LoadFailed(hook LoadFailedHook) {
+	if hook == nil {
+		return
+	}
+
+	hr.lock.Lock()
+	defer hr.lock.Unlock()
+
+	hr.loadFailed = append(hr.loadFailed, hook)
+}
+
+//BeforeUnload registers the hook called before unloading the plugin
+func (hr *HookRegistry) BeforeUnload(hook PluginHook) {
+	if hook == nil {
+		return
+	}
+
+	hr.lock.Lock()
+	defer hr.lock.Unlock()
+
+	hr.beforeUnload = append(hr.beforeUnload, hook)
+}
+
+//BeforeExecute registers the hook called before executing the plugin entries
+func (hr *HookRegistry) BeforeExecute(hook BeforeExecuteHook) {
+	if hook == nil {
+		return
+	}
+
+	hr.lock.Lock()
+	defer hr.lock.Unlock()
+
+	hr.beforeExecute = append(hr.beforeExecute, hook)
+}
+
+//AfterExecute registers the hook called after executing the plugin entries.
+//It's not called if the execution is vetoed.
+func (hr *HookRegistry) AfterExecute(hook AfterExecuteHook) {
+	if hook == nil {
+		return
+	}
+
+	hr.lock.Lock()
+	defer hr.lock.Unlock()
+
+	hr.afterExecute = append(hr.afterExecute, hook)
+}
+
+//callBeforeLoad calls the 'BeforeLoad' hooks until one vetoes
+func (hr *HookRegistry) callBeforeLoad(plugin *spec.Plugin) error {
+	hr.lock.RLock()
+	hooks := hr.beforeLoad
+	hr.lock.RUnlock()
+
+	return callPluginHooks(hookBeforeLoad, hooks, plugin)
+}
+
+//callAfterLoad calls the 'AfterLoad' hooks
+func (hr *HookRegistry) callAfterLoad(plugin *spec.Plugin) {
+	hr.lock.RLock()
+	hooks := hr.afterLoad
+	hr.lock.RUnlock()
+
+	for _, hook := range hooks {
+		hook(plugin)
+	}
+}
+
+//callLoadFailed calls the 'LoadFailed' hooks
+func (hr *HookRegistry) callLoadFailed(plugin *spec.Plugin, err error) {
+	hr.lock.RLock()
+	hooks := hr.loadFailed
+	hr.lock.RUnlock()
+
+	for _, hook := range hooks {
+		hook(plugin, err)
+	}
+}
+
+//callBeforeUnload calls the 'BeforeUnload' hooks until one vetoes
+func (hr *HookRegistry) callBeforeUnload(plugin *spec.Plugin) error {
+	hr.lock.RLock()
+	hooks := hr.beforeUnload
+	hr.lock.RUnlock()
+
+	return callPluginHooks(hookBeforeUnload, hooks, plugin)
+}
+
+//wrap the executor to call the 'BeforeExecute' and 'AfterExecute' hooks.
+//The hooks are got when executing, so the ones registered later also apply.
+func (hr *HookRegistry) wrap(plugin *spec.Plugin, exec spec.PluginExecutor) spec.PluginExecutor {
+	return func(ctx context.PluginContext) error {
+		hr.lock.RLock()
+		before, after := hr.beforeExecute, hr.afterExecute
+		hr.lock.RUnlock()
+
+		for _, hook := range before {
+			if err := hook(plugin, ctx); err != nil {
+				return &HookVetoError{
+					Hook:    hookBeforeExecute,
+					Plugin:  plugin.Name,
+					Version: plugin.Version,
+					Err:     err,
+				}
+			}
+		}
+
+		start := time.Now()
+		err := exec(ctx)
+		duration := time.Since(start)
+
+		for _, hook := range after {
+			hook(plugin, ctx, duration, err)
+		}
+
+		return err
+	}
+}
+
+func callPluginHooks(name string, hooks []PluginHook, plugin *spec.Plugin) error {
+	for _, hook := range hooks {
+		if err := hook(plugin); err != nil {
+			return &HookVetoError{
+				Hook:    name,
+				Plugin:  plugin.Name,
+				Version: plugin.Version,
+				Err:     err,
+			}
+		}
+	}
+
+	return nil
+}
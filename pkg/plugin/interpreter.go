@@ -0,0 +1,47 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/steven-zou/go-plugin/pkg/spec"
+	"github.com/steven-zou/go-plugin/pkg/symbols"
+	"github.com/traefik/yaegi/interp"
+	"github.com/traefik/yaegi/stdlib"
+)
+
+//The import path of the interpreted source package
+const interpretedPkgPath = "./"
+
+//loadInterpreted evaluates the plugin source package with the embedded interpreter
+//and builds the plugin item. The standard library and the go-plugin packages
+//(e.g: 'pkg/context') are available to the interpreted source.
+func loadInterpreted(plugin *spec.Plugin) (*spec.PluginItem, error) {
+	srcDir := plugin.Source.Path
+
+	i := interp.New(interp.Options{
+		SourcecodeFilesystem: os.DirFS(srcDir),
+	})
+	if err := i.Use(stdlib.Symbols); err != nil {
+		return nil, err
+	}
+	if err := i.Use(symbols.Symbols); err != nil {
+		return nil, err
+	}
+
+	if _, err := i.EvalPath(interpretedPkgPath); err != nil {
+		return nil, fmt.Errorf("failed to interpret plugin source '%s': %s", srcDir, err)
+	}
+
+	exported := i.Symbols(interpretedPkgPath)[interpretedPkgPath]
+
+	return newPluginItem(plugin, func(symbol string) (interface{}, error) {
+		v, ok := exported[symbol]
+		if !ok || !v.IsValid() || !v.CanInterface() {
+			return nil, &SymbolNotFoundError{Plugin: srcDir, Symbol: symbol, Err: errors.New("symbol is not exported by the interpreted package")}
+		}
+
+		return v.Interface(), nil
+	}, srcDir)
+}
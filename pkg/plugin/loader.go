@@ -6,27 +6,69 @@ import (
 	"io/ioutil"
 	"path/filepath"
 	sys_plugin "plugin"
+	"strings"
+	"sync"
 
 	"github.com/steven-zou/go-plugin/pkg"
 
 	"github.com/steven-zou/go-plugin/pkg/context"
+	"github.com/steven-zou/go-plugin/pkg/logger"
 	"github.com/steven-zou/go-plugin/pkg/spec"
 )
 
 //Loader defines the plugin load flow
 type Loader interface {
-	//Scan the plugin base dir and get the plugin candidates
+	//Scan the plugin base dir and get the plugin candidates.
+	//The candidates are the plugin dirs and bundles named with the plugin name
+	//or the 'name@version' layout, e.g: 'billing' or 'billing@1.2.0.plg'.
 	Scan(pluginBaseDir string) ([]string, error)
 
 	//Parse the plugin metadata
 	Parse(pluginPath string) (*spec.Plugin, error)
 
-	//Load the plugin executor
-	Load(plugin *spec.Plugin) (spec.PluginExecutor, error)
+	//Load the plugin and resolve the executors
+	Load(plugin *spec.Plugin) (*spec.PluginItem, error)
 }
 
+const (
+	//The name of the default entry function
+	defaultEntrySymbol = "Execute"
+
+	//The names of the optional lifecycle functions
+	initSymbol     = "Init"
+	shutdownSymbol = "Shutdown"
+	healthSymbol   = "Health"
+)
+
 //BaseLoader is an default implementation of Loader interface
-type BaseLoader struct{}
+type BaseLoader struct {
+	//Fetch the plugin source from git repository
+	fetcher *GitFetcher
+
+	//Build the plugin from source
+	builder Builder
+
+	//The logger passed to the loaded plugin clients
+	logger logger.Logger
+}
+
+//NewBaseLoader is constructor of BaseLoader.
+//The cache dir is used to keep the fetched source and
+//the build cache is used to keep the built so files.
+func NewBaseLoader(cacheDir string, buildCache BuildCache) *BaseLoader {
+	return &BaseLoader{
+		fetcher: NewGitFetcher(filepath.Join(cacheDir, "git")),
+		builder: NewGoBuilder(buildCache),
+		logger:  logger.Default(),
+	}
+}
+
+//SetLogger implements the LoggerSetter interface
+func (bl *BaseLoader) SetLogger(l logger.Logger) {
+	if l != nil {
+		bl.logger = l
+	}
+}
 
 //Scan implements same method of Loader interface
 func (bl *BaseLoader) Scan(pluginBaseDir string) ([]string, error) {
@@ -45,7 +87,8 @@ func (bl *BaseLoader) Scan(pluginBaseDir string) ([]string, error) {
 
 	candidates := make([]string, 0)
 	for _, f := range files {
-		if f.IsDir() {
+		//Plugin dirs and plugin bundles are the candidates
+		if f.IsDir() || (f.Mode().IsRegular() && filepath.Ext(f.Name()) == pkg.PluginBundleExt) {
 			candidates = append(candidates, filepath.Join(pluginBaseDir, f.Name()))
 		}
 	}
@@ -59,29 +102,252 @@ func (bl *BaseLoader) Parse(pluginPath string) (*spec.Plugin, error) {
 }
 
 //Load implements same method of Loader interface
-func (bl *BaseLoader) Load(plugin *spec.Plugin) (spec.PluginExecutor, error) {
+func (bl *BaseLoader) Load(plugin *spec.Plugin) (*spec.PluginItem, error) {
 	if plugin == nil {
 		return nil, errors.New("nil plugin spec")
 	}
 
-	if !pkg.FileExists(plugin.Source.Path) {
-		return nil, fmt.Errorf("plugin so file '%s' is not existsing", plugin.Source.Path)
+	if plugin.Source == nil {
+		return nil, errors.New("plugin source missing")
+	}
+
+	var soFile string
+	switch plugin.Source.Mode {
+	case pkg.PluginSourceModeLocal:
+		soFile = plugin.Source.Path
+	case pkg.PluginSourceModeLocalSrc:
+		if bl.builder == nil {
+			return nil, errors.New("loader is not able to build plugins, create it with NewBaseLoader")
+		}
+
+		var err error
+		if soFile, err = bl.builder.Build(plugin, plugin.Source.Path); err != nil {
+			return nil, err
+		}
+	case pkg.PluginSourceModeProcess:
+		//Run out of process
+		return loadProcess(plugin, bl.logger)
+	case pkg.PluginSourceModeInterpreted:
+		//Run with the interpreter
+		return loadInterpreted(plugin)
+	case pkg.PluginSourceModeWasm:
+		//Run in the wasm sandbox
+		return loadWasm(plugin, bl.logger)
+	case pkg.PluginSourceModeRemote:
+		if bl.fetcher == nil || bl.builder == nil {
+			return nil, errors.New("loader is not able to build plugins, create it with NewBaseLoader")
+		}
+
+		srcDir, err := bl.fetcher.Fetch(plugin)
+		if err != nil {
+			return nil, err
+		}
+
+		if soFile, err = bl.builder.Build(plugin, srcDir); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported plugin source mode '%s'", plugin.Source.Mode)
+	}
+
+	if !pkg.FileExists(soFile) {
+		return nil, fmt.Errorf("plugin so file '%s' is not existsing", soFile)
+	}
+
+	//Check before opening to avoid the cryptic errors of the runtime
+	if err := CheckCompatibility(soFile); err != nil {
+		var noModuleInfo *NoModuleInfoError
+		if !errors.As(err, &noModuleInfo) {
+			return nil, err
+		}
+		bl.logger.Warn("Module versions of plugin are not checked", "plugin", plugin.Name, "version", plugin.Version, "reason", err)
+	}
+
+	p, err := openSoFile(soFile)
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := newPluginItem(plugin, func(symbol string) (interface{}, error) {
+		sym, err := p.Lookup(symbol)
+		if err != nil {
+			//Only failed if the symbol is not existing
+			return nil, &SymbolNotFoundError{Plugin: soFile, Symbol: symbol, Err: err}
+		}
+
+		return sym, nil
+	}, soFile)
+	if err != nil {
+		return nil, err
+	}
+	item.Handle = p
+
+	return item, nil
+}
+
+//openedSoFiles keeps the digests of the so files opened in the process
+var openedSoFiles = struct {
+	lock    *sync.Mutex
+	digests map[string]string
+}{
+	lock:    new(sync.Mutex),
+	digests: make(map[string]string),
+}
+
+//openSoFile opens the so file with the go runtime.
+//The runtime never unloads the opened plugins and returns the cached plugin for
+//the path opened before, so the so file changed in place (e.g: replaced with a new
+//version) can not be reloaded, an error is returned instead of the stale plugin.
+func openSoFile(soFile string) (*sys_plugin.Plugin, error) {
+	path, err := filepath.EvalSymlinks(soFile)
+	if err != nil {
+		return nil, err
+	}
+	if path, err = filepath.Abs(path); err != nil {
+		return nil, err
+	}
+
+	digest, err := fileDigest(path)
+	if err != nil {
+		return nil, err
+	}
+
+	openedSoFiles.lock.Lock()
+	defer openedSoFiles.lock.Unlock()
+
+	if opened, ok := openedSoFiles.digests[path]; ok && opened != digest {
+		return nil, fmt.Errorf("plugin so file %s is changed after it was opened, the go runtime can not reload it: put the new version into a new file or dir (e.g: 'name@version') or restart the process", soFile)
 	}
 
-	p, err := sys_plugin.Open(plugin.Source.Path)
+	p, err := sys_plugin.Open(path)
 	if err != nil {
+		if strings.Contains(err.Error(), "plugin already loaded") {
+			//The go runtime identifies the plugins with the plugin path
+			return nil, fmt.Errorf("%s: another so file of the same plugin path is already loaded, build the versions of the plugin with its go files ('go build -buildmode=plugin *.go') instead of the package to make the plugin paths unique", err)
+		}
 		return nil, err
 	}
+	openedSoFiles.digests[path] = digest
+
+	return p, nil
+}
+
+//symbolLookup looks up the exported symbol of the plugin by name,
+//*SymbolNotFoundError is returned if the symbol is not existing
+type symbolLookup func(symbol string) (interface{}, error)
+
+//newPluginItem resolves the entries and the lifecycle functions with the symbol lookup
+//and builds the plugin item. The source is the file or dir where the plugin is loaded from.
+func newPluginItem(plugin *spec.Plugin, lookup symbolLookup, source string) (*spec.PluginItem, error) {
+	item := &spec.PluginItem{
+		Spec:    plugin,
+		Entries: make(map[string]spec.PluginExecutor),
+	}
+
+	//Resolve and type check all the entries
+	for label, symbol := range plugin.Entries {
+		entry, err := lookupEntry(lookup, symbol, source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve entry '%s': %s", label, err)
+		}
+		item.Entries[label] = entry
+	}
+
+	err := resolveExecutor(item, func() (spec.PluginExecutor, error) {
+		return lookupEntry(lookup, defaultEntrySymbol, source)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	//The lifecycle functions are optional
+	if item.Init, err = lookupLifecycle(lookup, initSymbol, source); err != nil {
+		return nil, err
+	}
+	if item.Shutdown, err = lookupLifecycle(lookup, shutdownSymbol, source); err != nil {
+		return nil, err
+	}
+	if item.Health, err = lookupLifecycle(lookup, healthSymbol, source); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+//lookupLifecycle looks up the optional lifecycle function and checks its signature.
+//If the function is not exported, nil is returned.
+func lookupLifecycle(lookup symbolLookup, symbol string, source string) (spec.LifecycleFunc, error) {
+	fn, err := lookup(symbol)
+	if err != nil {
+		var notFound *SymbolNotFoundError
+		if errors.As(err, &notFound) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to lookup lifecycle function '%s' in plugin '%s': %s", symbol, source, err)
+	}
 
-	exec, err := p.Lookup("Execute")
+	pFn, ok := fn.(func(ctx context.PluginContext) error)
+	if !ok {
+		return nil, fmt.Errorf("lifecycle function '%s' in plugin '%s' has type %T but 'func(context.PluginContext) error' expected", symbol, source, fn)
+	}
+
+	return pFn, nil
+}
+
+//resolveExecutor sets the default executor of the plugin item with the resolved entries.
+//If no entries are declared, the default entry function is used.
+func resolveExecutor(item *spec.PluginItem, defaultEntry func() (spec.PluginExecutor, error)) error {
+	plugin := item.Spec
+
+	switch {
+	case len(plugin.Entries) == 0:
+		exec, err := defaultEntry()
+		if err != nil {
+			return err
+		}
+		item.Executor = exec
+	case len(plugin.DefaultEntry) > 0:
+		entry, ok := item.Entries[plugin.DefaultEntry]
+		if !ok {
+			return fmt.Errorf("default entry '%s' is not declared in the entries", plugin.DefaultEntry)
+		}
+		item.Executor = entry
+	default:
+		item.Executor = dispatchByLabel(plugin.Name, item.Entries)
+	}
+
+	return nil
+}
+
+//lookupEntry looks up the exported entry function and checks its signature
+func lookupEntry(lookup symbolLookup, symbol string, source string) (spec.PluginExecutor, error) {
+	exec, err := lookup(symbol)
 	if err != nil {
 		return nil, err
 	}
 
 	pExec, ok := exec.(func(ctx context.PluginContext) error)
 	if !ok {
-		return nil, fmt.Errorf("failed to lookup entry function 'Execute' in plugin so file '%s'", plugin.Source.Path)
+		return nil, fmt.Errorf("failed to lookup entry function '%s' in plugin '%s': type %T is not 'func(context.PluginContext) error'", symbol, source, exec)
 	}
 
 	return pExec, nil
 }
+
+//dispatchByLabel returns an executor which calls the entry matching the label in the plugin context
+func dispatchByLabel(pluginName string, entries map[string]spec.PluginExecutor) spec.PluginExecutor {
+	return func(ctx context.PluginContext) error {
+		label := ctx.GetValue(context.LabelKey)
+		if label == nil {
+			return fmt.Errorf("label is not set in the plugin context for calling plugin %s", pluginName)
+		}
+
+		entry, ok := entries[fmt.Sprintf("%s", label)]
+		if !ok {
+			return fmt.Errorf("entry with label '%s' is not existing in plugin %s", label, pluginName)
+		}
+
+		return entry(ctx)
+	}
+}
@@ -1,18 +1,34 @@
 package plugin
 
 import (
+	sys_context "context"
 	"errors"
 	"fmt"
-	"log"
+	"os"
 	"path/filepath"
+	sys_plugin "plugin"
+	"sync"
+	"time"
 
 	"github.com/steven-zou/go-plugin/pkg"
+	"github.com/steven-zou/go-plugin/pkg/context"
+	"github.com/steven-zou/go-plugin/pkg/logger"
 	"github.com/steven-zou/go-plugin/pkg/spec"
 )
 
 //DefaultManager is the default plugin manager
 var DefaultManager = NewBaseManager()
 
+//DefaultLifecycleTimeout is the default timeout of calling the lifecycle functions of plugin
+const DefaultLifecycleTimeout = 30 * time.Second
+
+//LoggerSetter is implemented by the components accepting the injected logger,
+//e.g: the loader and the validators
+type LoggerSetter interface {
+	//Set the logger, nil is ignored
+	SetLogger(l logger.Logger)
+}
+
 //Manager defines the related operations of one plugin manager
 //should support.
 //Manager is used to load, organize and maintain the plugins.
@@ -22,21 +38,193 @@ type Manager interface {
 	//an error will be returned.
 	SetPluginBaseDir(dir string) error
 
+	//Set the managed cache dir where to extract the plugin bundles,
+	//clone the plugin repositories and keep the built so files.
+	//If the dir can not be created, an error will be returned.
+	//It should be called before loading the plugins and starting the watcher.
+	SetCacheDir(dir string) error
+
+	//Set the timeout of calling the lifecycle functions ('Init', 'Shutdown'
+	//and 'Health') of the plugins.
+	SetLifecycleTimeout(timeout time.Duration)
+
+	//Set the logger of the manager. It's also passed to the loader and the
+	//validators implementing LoggerSetter. The plugins get the child logger
+	//tagged with their name and version by 'context.Logger'.
+	//Use 'logger.Discard' to silence the logs.
+	//It should be called before loading the plugins and starting the watcher.
+	SetLogger(l logger.Logger)
+
+	//Set the tracer called around validating, opening, initializing and executing
+	//the plugins. Nil tracer disables the tracing, which is the default.
+	SetTracer(tracer Tracer)
+
+	//Set the grace period to watch the health of the upgraded plugin.
+	//The upgrade is rolled back if the plugin is not healthy in the period.
+	SetUpgradeGracePeriod(period time.Duration)
+
+	//Get the cache of the plugins built from source.
+	//It can be used to inspect and purge the built so files.
+	BuildCache() BuildCache
+
+	//Get the registry of the lifecycle hooks.
+	//The hooks are called when loading, unloading and executing the plugins,
+	//the 'Before*' hooks can veto the operation by returning an error.
+	Hooks() *HookRegistry
+
+	//Get the metrics of the plugins. The executions, errors, panics, latency and
+	//in-flight executions of the plugin entries and the counts of loading, unloading
+	//and upgrading the plugins are collected. It's a http.Handler serving the
+	//metrics in the Prometheus text format.
+	Metrics() *Metrics
+
 	//Load all the plugins from the base plugin dir.
+	//The plugins are loaded in the topological order of their dependencies,
+	//the plugins in the dependency cycles or depending on the failed ones are skipped.
 	//Any issues happened, an error will be returned.
 	LoadPlugins() error
 
 	//Load plugin with the specified name.
+	//The name can be '<name>' or '<name>@<version>' matching the plugin dir
+	//or the plugin bundle ('<name>.plg' or '<name>@<version>.plg').
+	//The dependencies of the plugin should have been loaded.
 	//If failed to load, an error will be returned.
 	LoadPlugin(name string) error
 
-	//Unload plugin with the specified name.
+	//Unload the latest version of the plugin with the specified name.
+	//The running executions are drained (within the lifecycle timeout) before
+	//the 'Shutdown' function of the plugin is called if existing.
+	//If other loaded plugins still depend on it, a *DependentsError will be returned.
 	//If failed to unload, an error will be returned.
 	UnloadPlugin(name string) error
 
-	//Get the plugin with the specified name.
+	//Unload the plugin with the specified name and version.
+	//If the version is empty or 'latest', the latest version is unloaded.
+	//If other loaded plugins still depend on it, a *DependentsError will be returned.
+	//If failed to unload, an error will be returned.
+	UnloadPluginVersion(name string, version string) error
+
+	//Unload the plugin with the specified name and version together with
+	//the loaded plugins depending on it, the dependents are unloaded first.
+	//If the version is empty or 'latest', the latest version is unloaded.
+	//If failed to unload, an error will be returned.
+	UnloadPluginCascade(name string, version string) error
+
+	//Upgrade the plugin with the specified name to the highest version found in
+	//the plugin base dir, which should be higher than the loaded one. The 'so' file
+	//changed in place can not be reloaded by the go runtime and the upgrade fails.
+	//The new version is loaded and initialized next to the
+	//current one, then swapped in atomically. If the new version fails the health
+	//check in the grace period, the current one is restored and a *RollbackError
+	//is returned. The replaced one is shut down after its running executions are drained.
+	UpgradePlugin(name string) error
+
+	//Start watching the plugin base dir by polling it with the interval.
+	//The new, changed and removed plugin dirs or bundles are loaded, upgraded
+	//or unloaded once they are stable for the debounce period, so the partial
+	//copies are not picked up. The ones existing when starting are not touched.
+	//The changed ones without a newer version (e.g: rebuilt in place) are reloaded as well.
+	//The 'so' files changed in place can not be reloaded and are reported as failed upgrades.
+	//Zero interval or debounce means the default one.
+	//If the watcher is already started, an error will be returned.
+	StartWatcher(interval time.Duration, debounce time.Duration) error
+
+	//Stop watching the plugin base dir
+	StopWatcher()
+
+	//Subscribe the actions taken by the watcher.
+	//The events are dropped for the subscriber if its buffer is full.
+	//Call the returned function to cancel the subscription and close the channel.
+	SubscribeWatchEvents() (<-chan *WatchEvent, func())
+
+	//Subscribe the state changes of the plugins: discovered, validated, validation-failed,
+	//loaded, load-failed, unloaded, upgraded, executed, retrying, the circuit state
+	//changes, quarantined and enabled.
+	//The events are buffered with the buffer size (zero means the default one),
+	//once the buffer is full, the events are dropped with the drop policy.
+	//Call the returned function to cancel the subscription and close the channel.
+	Subscribe(buffer int, policy DropPolicy) (<-chan *Event, func())
+
+	//Check the health of the latest version of the plugin with the specified name by
+	//calling the 'Health' function of the plugin if existing.
+	//If plugin is not existing or not healthy, an error will be returned.
+	CheckHealth(name string) error
+
+	//Get the latest version of the plugin with the specified name.
 	//If plugin is not existing, an error will be returned.
 	GetPlugin(name string) (*spec.Plugin, spec.PluginExecutor, error)
+
+	//Get the plugin with the specified name and version.
+	//If the version is empty or 'latest', the latest version is returned.
+	//If plugin is not existing, an error will be returned.
+	GetPluginVersion(name string, version string) (*spec.Plugin, spec.PluginExecutor, error)
+
+	//Get the highest version of the plugin with the specified name which
+	//satisfies the semver constraint, e.g: '^1.4, <2.0'.
+	//If no versions match, a *NoMatchingVersionError will be returned.
+	GetPluginMatching(name string, constraint string) (*spec.Plugin, spec.PluginExecutor, error)
+
+	//Check the plugins required by the host are loaded with the matching versions.
+	//The requirements can be read from the json file with 'ParseRequirements'.
+	//If any requirements are not satisfied, a *RequirementsError will be returned.
+	CheckRequirements(requirements *spec.Requirements) error
+
+	//Execute the plugin with the specified name in a plugin context derived from
+	//the context with the values. The timeout in the options or the default one
+	//in the 'plugin.json' is applied. The panic of the plugin is recovered into
+	//a *PanicError, and a *TimeoutError is returned if the timeout is reached.
+	//The result carries the execution ID for correlation, which is also set to
+	//the plugin context with the key 'context.ExecutionIDKey'. The result is
+	//returned together with the execution error if the plugin is executed.
+	Execute(ctx sys_context.Context, name string, values map[string]interface{}, opts *ExecuteOptions) (*ExecutionResult, error)
+
+	//Get the concurrency stats of the plugin with the specified name and version:
+	//the limits, the running and queued executions and the rejected count.
+	//The executions over the limits in the 'plugin.json' wait in the queue or
+	//are rejected with an *OverloadedError.
+	//If the version is empty or 'latest', the latest version is used.
+	//If plugin is not existing, an error will be returned.
+	GetConcurrency(name string, version string) (*ConcurrencyStats, error)
+
+	//Get the state of the circuit breaker of the plugin with the specified name and version.
+	//The executions fail fast with a *CircuitOpenError while the circuit is open.
+	//The plugins without the circuit breaker in the 'plugin.json' are always closed.
+	//If the version is empty or 'latest', the latest version is used.
+	//If plugin is not existing, an error will be returned.
+	GetCircuitState(name string, version string) (CircuitState, error)
+
+	//Set the consecutive crashes (panicking or the plugin process exiting) of the
+	//executions to quarantine the plugin automatically.
+	//Zero or negative disables the automatic quarantine.
+	SetQuarantineThreshold(crashes int)
+
+	//Quarantine the plugin with the specified name and version with the reason.
+	//The quarantined plugin stays loaded, but its executions are rejected with
+	//a *QuarantinedError until it's enabled again.
+	//If the version is empty or 'latest', the latest version is used.
+	//If plugin is not existing, an error will be returned.
+	QuarantinePlugin(name string, version string, reason string) error
+
+	//Enable the quarantined plugin with the specified name and version.
+	//If the version is empty or 'latest', the latest version is used.
+	//If plugin is not existing or not quarantined, an error will be returned.
+	EnablePlugin(name string, version string) error
+
+	//Get the quarantine of the plugin with the specified name and version,
+	//nil if the plugin is not quarantined.
+	//If the version is empty or 'latest', the latest version is used.
+	//If plugin is not existing, an error will be returned.
+	GetQuarantine(name string, version string) (*Quarantine, error)
+
+	//Get the entry executor of the latest version of the plugin with the specified label.
+	//If the label is empty, the default executor is returned.
+	//If plugin or entry is not existing, an error will be returned.
+	GetEntry(name string, label string) (spec.PluginExecutor, error)
+
+	//Look up the exported symbol of the latest version of the plugin with the specified name.
+	//Use the generic 'LookupSymbol' function to get the typed symbol.
+	//If plugin or symbol is not existing, an error will be returned.
+	Lookup(name string, symbol string) (sys_plugin.Symbol, error)
 }
 
 //BaseManager is implemented as default plugin manager
@@ -44,6 +232,15 @@ type BaseManager struct {
 	//Keep the base dir of the plugins
 	basePluginBaseDir string
 
+	//Keep the managed cache dir
+	cacheDir string
+
+	//The plugin bundle extractor
+	bundles *BundleExtractor
+
+	//The cache of the built plugins
+	buildCache BuildCache
+
 	//The plugin loader
 	loader Loader
 
@@ -52,17 +249,79 @@ type BaseManager struct {
 
 	//The list to keep the loaded one
 	store Store
+
+	//The lifecycle hooks
+	hooks *HookRegistry
+
+	//The metrics of the plugins
+	metrics *Metrics
+
+	//The tracer of the plugin operations
+	tracer Tracer
+
+	//The logger of the manager
+	logger logger.Logger
+
+	//The timeout of calling lifecycle functions
+	lifecycleTimeout time.Duration
+
+	//The grace period to watch the health of the upgraded plugin
+	upgradeGracePeriod time.Duration
+
+	//internal lock
+	lock *sync.Mutex
+
+	//The consecutive crashes to quarantine the plugin, accessed atomically
+	quarantineThreshold int32
+
+	//The execution states of the loaded plugin items
+	executions map[*spec.PluginItem]*executionState
+
+	//The names of the plugins being upgraded
+	upgrading map[string]bool
+
+	//The running watcher of the plugin base dir
+	watcher *watcher
+
+	//The broadcaster of the watch events
+	watchEvents *broadcaster[*WatchEvent]
+
+	//The broadcaster of the plugin events
+	events *broadcaster[*Event]
 }
 
 //NewBaseManager is constructor of BaseManager
 func NewBaseManager() Manager {
+	cacheDir := filepath.Join(os.TempDir(), "go-plugin")
+	buildCache := NewBaseBuildCache(filepath.Join(cacheDir, "builds"), DefaultBuildCacheSize)
+
 	return &BaseManager{
-		loader: &BaseLoader{},
+		cacheDir:   cacheDir,
+		bundles:    NewBundleExtractor(filepath.Join(cacheDir, "bundles")),
+		buildCache: buildCache,
+		loader:     NewBaseLoader(cacheDir, buildCache),
 		validtor: NewBaseValidatorChain(
 			&JSONFileValidator{},
 			&SpecValidator{},
-			&LocalSourceValidator{}),
-		store: NewBaseStore(),
+			&LocalSourceValidator{},
+			&CompatibilityValidator{},
+			&LocalSrcValidator{},
+			&ProcessSourceValidator{},
+			&WasmSourceValidator{},
+			&RemoteSourceValidator{}),
+		store:               NewBaseStore(),
+		hooks:               NewHookRegistry(),
+		metrics:             NewMetrics(nil),
+		tracer:              noopTracer{},
+		logger:              logger.Default(),
+		lifecycleTimeout:    DefaultLifecycleTimeout,
+		upgradeGracePeriod:  DefaultUpgradeGracePeriod,
+		lock:                new(sync.Mutex),
+		quarantineThreshold: DefaultQuarantineThreshold,
+		executions:          make(map[*spec.PluginItem]*executionState),
+		upgrading:           make(map[string]bool),
+		watchEvents:         newBroadcaster[*WatchEvent](),
+		events:              newBroadcaster[*Event](),
 	}
 }
 
@@ -70,7 +329,9 @@ func NewBaseManager() Manager {
 func (bm *BaseManager) SetPluginBaseDir(dir string) error {
 	if len(dir) > 0 {
 		if pkg.FileExists(dir) {
+			bm.lock.Lock()
 			bm.basePluginBaseDir = dir
+			bm.lock.Unlock()
 			return nil
 		}
 	}
@@ -78,10 +339,103 @@ func (bm *BaseManager) SetPluginBaseDir(dir string) error {
 	return fmt.Errorf("%s is not a valid plugin base dir path", dir)
 }
 
+//pluginBaseDir returns the base dir of the plugins, it can be changed by the watcher goroutine concurrently
+func (bm *BaseManager) pluginBaseDir() string {
+	bm.lock.Lock()
+	defer bm.lock.Unlock()
+
+	return bm.basePluginBaseDir
+}
+
+//SetCacheDir implements the interface method
+func (bm *BaseManager) SetCacheDir(dir string) error {
+	if len(dir) == 0 {
+		return errors.New("cache dir cannot be empty")
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("%s is not a valid cache dir path: %s", dir, err)
+	}
+
+	bm.cacheDir = dir
+	bm.bundles = NewBundleExtractor(filepath.Join(dir, "bundles"))
+	bm.buildCache = NewBaseBuildCache(filepath.Join(dir, "builds"), DefaultBuildCacheSize)
+	bm.loader = NewBaseLoader(dir, bm.buildCache)
+	if ls, ok := bm.loader.(LoggerSetter); ok {
+		ls.SetLogger(bm.logger)
+	}
+
+	return nil
+}
+
+//SetLogger implements the interface method
+func (bm *BaseManager) SetLogger(l logger.Logger) {
+	if l == nil {
+		return
+	}
+
+	bm.logger = l
+	if ls, ok := bm.loader.(LoggerSetter); ok {
+		ls.SetLogger(l)
+	}
+	if ls, ok := bm.validtor.(LoggerSetter); ok {
+		ls.SetLogger(l)
+	}
+}
+
+//SetLifecycleTimeout implements the interface method
+func (bm *BaseManager) SetLifecycleTimeout(timeout time.Duration) {
+	if timeout > 0 {
+		bm.lock.Lock()
+		bm.lifecycleTimeout = timeout
+		bm.lock.Unlock()
+	}
+}
+
+//activeLifecycleTimeout returns the lifecycle timeout, it can be changed while the watcher is upgrading
+func (bm *BaseManager) activeLifecycleTimeout() time.Duration {
+	bm.lock.Lock()
+	defer bm.lock.Unlock()
+
+	return bm.lifecycleTimeout
+}
+
+//SetUpgradeGracePeriod implements the interface method
+func (bm *BaseManager) SetUpgradeGracePeriod(period time.Duration) {
+	if period >= 0 {
+		bm.lock.Lock()
+		bm.upgradeGracePeriod = period
+		bm.lock.Unlock()
+	}
+}
+
+//activeGracePeriod returns the upgrade grace period, it can be changed while the watcher is upgrading
+func (bm *BaseManager) activeGracePeriod() time.Duration {
+	bm.lock.Lock()
+	defer bm.lock.Unlock()
+
+	return bm.upgradeGracePeriod
+}
+
+//BuildCache implements the interface method
+func (bm *BaseManager) BuildCache() BuildCache {
+	return bm.buildCache
+}
+
+//Hooks implements the interface method
+func (bm *BaseManager) Hooks() *HookRegistry {
+	return bm.hooks
+}
+
+//Metrics implements the interface method
+func (bm *BaseManager) Metrics() *Metrics {
+	return bm.metrics
+}
+
 //LoadPlugins implements the interface method
 func (bm *BaseManager) LoadPlugins() error {
 	//scan plugin base dir
-	paths, err := bm.loader.Scan(bm.basePluginBaseDir)
+	paths, err := bm.loader.Scan(bm.pluginBaseDir())
 	if err != nil {
 		return err
 	}
@@ -91,15 +445,52 @@ func (bm *BaseManager) LoadPlugins() error {
 		return nil
 	}
 
-	//loop all
+	//Keep the errors of the failed plugins to explain the skipped dependents
+	failed := make(map[string]error)
+
+	//validate all
+	specs := make([]*spec.Plugin, 0, len(paths))
 	for _, p := range paths {
-		log.Printf("[INFO]: Found plugin: %s\n", p)
-		if err := bm.loadPlugin(p); err != nil {
-			log.Printf("[ERROR]: Plugin loading error: %s\n", err)
+		bm.logger.Info("Found plugin", "path", p)
+		bm.emitPath(EventDiscovered, p, nil)
+		pluginSpec, err := bm.validatePlugin(p)
+		if err != nil {
+			name, _ := pkg.SplitPluginDirName(filepath.Base(p))
+			failed[name] = err
+			bm.logger.Error("Plugin loading error", "path", p, "error", err)
+			continue
 		}
+		specs = append(specs, pluginSpec)
 	}
 
-	log.Printf("[INFO]: %d plugins loaded", bm.store.Size())
+	//load in the order of dependencies
+	sorted, cyclic := sortByDependencies(specs)
+	for _, pluginSpec := range specs {
+		if err, ok := cyclic[pluginSpec]; ok {
+			bm.logger.Error("Plugin loading error", "plugin", pluginSpec.Name, "version", pluginSpec.Version, "error", err)
+			bm.emit(EventLoadFailed, pluginSpec, err)
+		}
+	}
+
+	for _, pluginSpec := range sorted {
+		err := checkDependencies(bm.store, pluginSpec)
+		if de, ok := err.(*DependencyError); ok {
+			if cause, ok := failed[de.Dependency.Name]; ok {
+				de.Err = fmt.Errorf("%s, the dependency failed to load: %s", de.Err, cause)
+			}
+		}
+		if err != nil {
+			bm.emit(EventLoadFailed, pluginSpec, err)
+		} else {
+			err = bm.load(pluginSpec)
+		}
+		if err != nil {
+			failed[pluginSpec.Name] = err
+			bm.logger.Error("Plugin loading error", "plugin", pluginSpec.Name, "version", pluginSpec.Version, "error", err)
+		}
+	}
+
+	bm.logger.Info("Plugins loaded", "count", bm.store.Size())
 
 	return nil
 }
@@ -110,28 +501,119 @@ func (bm *BaseManager) LoadPlugin(name string) error {
 		return errors.New("plugin name cannot be empty")
 	}
 
-	pluginPath := filepath.Join(bm.basePluginBaseDir, name)
+	pluginPath := filepath.Join(bm.pluginBaseDir(), name)
+	if !pkg.FileExists(pluginPath) {
+		//Try the bundle
+		if bundlePath := pluginPath + pkg.PluginBundleExt; pkg.IsBundle(bundlePath) {
+			pluginPath = bundlePath
+		}
+	}
 
 	return bm.loadPlugin(pluginPath)
 }
 
 //UnloadPlugin implements the interface method
 func (bm *BaseManager) UnloadPlugin(name string) error {
+	return bm.UnloadPluginVersion(name, LatestVersion)
+}
+
+//UnloadPluginVersion implements the interface method
+func (bm *BaseManager) UnloadPluginVersion(name string, version string) error {
 	if len(name) == 0 {
 		return errors.New("plugin name cannot be empty")
 	}
 
-	if _, ok := bm.store.Get(name); !ok {
-		return fmt.Errorf("plugin %s is not loaded", name)
+	existing, ok := bm.store.GetVersion(name, version)
+	if !ok {
+		return fmt.Errorf("plugin %s is not loaded", pluginRef(name, version))
 	}
 
-	if _, ok := bm.store.Remove(name); !ok {
-		return fmt.Errorf("failed to unload plugin %s", name)
+	if found := dependents(bm.store, existing); len(found) > 0 {
+		de := &DependentsError{
+			Plugin:     name,
+			Version:    existing.Spec.Version,
+			Dependents: make([]string, 0, len(found)),
+		}
+		for _, d := range found {
+			de.Dependents = append(de.Dependents, fmt.Sprintf("%s:%s", d.Spec.Name, d.Spec.Version))
+		}
+
+		return de
 	}
 
+	return bm.unload(existing)
+}
+
+//UnloadPluginCascade implements the interface method
+func (bm *BaseManager) UnloadPluginCascade(name string, version string) error {
+	if len(name) == 0 {
+		return errors.New("plugin name cannot be empty")
+	}
+
+	existing, ok := bm.store.GetVersion(name, version)
+	if !ok {
+		return fmt.Errorf("plugin %s is not loaded", pluginRef(name, version))
+	}
+
+	return bm.unloadCascade(existing, make(map[*spec.PluginItem]bool))
+}
+
+//unloadCascade unloads the dependents of the plugin recursively and then the plugin itself
+func (bm *BaseManager) unloadCascade(pluginItem *spec.PluginItem, visited map[*spec.PluginItem]bool) error {
+	if visited[pluginItem] {
+		return nil
+	}
+	visited[pluginItem] = true
+
+	for _, d := range dependents(bm.store, pluginItem) {
+		if err := bm.unloadCascade(d, visited); err != nil {
+			return err
+		}
+	}
+
+	return bm.unload(pluginItem)
+}
+
+//unload removes the plugin item out of the store, drains its running executions
+//and calls its 'Shutdown' function
+func (bm *BaseManager) unload(existing *spec.PluginItem) error {
+	name, version := existing.Spec.Name, existing.Spec.Version
+
+	if err := bm.hooks.callBeforeUnload(existing.Spec); err != nil {
+		return err
+	}
+
+	pluginItem, ok := bm.store.RemoveVersion(name, version)
+	if !ok {
+		return fmt.Errorf("failed to unload plugin %s:%s", name, version)
+	}
+
+	bm.drain(pluginItem)
+
+	if err := bm.callLifecycle(pluginItem, shutdownSymbol, pluginItem.Shutdown); err != nil {
+		err = fmt.Errorf("plugin %s:%s is unloaded but failed to shutdown: %s", name, pluginItem.Spec.Version, err)
+		bm.emit(EventUnloaded, pluginItem.Spec, err)
+		return err
+	}
+	bm.emit(EventUnloaded, pluginItem.Spec, nil)
+
 	return nil
 }
 
+//CheckHealth implements the interface method
+func (bm *BaseManager) CheckHealth(name string) error {
+	if len(name) == 0 {
+		return errors.New("plugin name cannot be empty")
+	}
+
+	pluginItem, ok := bm.store.Get(name)
+	if !ok {
+		return fmt.Errorf("plugin with name '%s' is not existing", name)
+	}
+
+	return bm.callLifecycle(pluginItem, healthSymbol, pluginItem.Health)
+}
+
 //GetPlugin implements the interface method
 func (bm *BaseManager) GetPlugin(name string) (*spec.Plugin, spec.PluginExecutor, error) {
 	if len(name) == 0 {
@@ -146,34 +628,322 @@ func (bm *BaseManager) GetPlugin(name string) (*spec.Plugin, spec.PluginExecutor
 	return pluginItem.Spec, pluginItem.Executor, nil
 }
 
+//GetPluginVersion implements the interface method
+func (bm *BaseManager) GetPluginVersion(name string, version string) (*spec.Plugin, spec.PluginExecutor, error) {
+	if len(name) == 0 {
+		return nil, nil, errors.New("plugin name cannot be empty")
+	}
+
+	pluginItem, ok := bm.store.GetVersion(name, version)
+	if !ok {
+		return nil, nil, fmt.Errorf("plugin '%s' is not existing", pluginRef(name, version))
+	}
+
+	return pluginItem.Spec, pluginItem.Executor, nil
+}
+
+//GetPluginMatching implements the interface method
+func (bm *BaseManager) GetPluginMatching(name string, constraint string) (*spec.Plugin, spec.PluginExecutor, error) {
+	if len(name) == 0 {
+		return nil, nil, errors.New("plugin name cannot be empty")
+	}
+
+	pluginItem, err := matchVersion(name, bm.store.Versions(name), constraint)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pluginItem.Spec, pluginItem.Executor, nil
+}
+
+//CheckRequirements implements the interface method
+func (bm *BaseManager) CheckRequirements(requirements *spec.Requirements) error {
+	return checkRequirements(bm.store, requirements)
+}
+
+//GetEntry implements the interface method
+func (bm *BaseManager) GetEntry(name string, label string) (spec.PluginExecutor, error) {
+	if len(name) == 0 {
+		return nil, errors.New("plugin name cannot be empty")
+	}
+
+	pluginItem, ok := bm.store.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("plugin with name '%s' is not existing", name)
+	}
+
+	if len(label) == 0 {
+		return pluginItem.Executor, nil
+	}
+
+	entry, ok := pluginItem.Entries[label]
+	if !ok {
+		return nil, fmt.Errorf("entry with label '%s' is not existing in plugin '%s'", label, name)
+	}
+
+	return entry, nil
+}
+
+//Lookup implements the interface method
+func (bm *BaseManager) Lookup(name string, symbol string) (sys_plugin.Symbol, error) {
+	if len(name) == 0 {
+		return nil, errors.New("plugin name cannot be empty")
+	}
+
+	pluginItem, ok := bm.store.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("plugin with name '%s' is not existing", name)
+	}
+
+	if pluginItem.Handle == nil {
+		return nil, &SymbolNotFoundError{
+			Plugin: name,
+			Symbol: symbol,
+			Err:    errors.New("plugin is not loaded from so file"),
+		}
+	}
+
+	sym, err := pluginItem.Handle.Lookup(symbol)
+	if err != nil {
+		return nil, &SymbolNotFoundError{
+			Plugin: name,
+			Symbol: symbol,
+			Err:    err,
+		}
+	}
+
+	return sym, nil
+}
+
 func (bm *BaseManager) loadPlugin(pluginPath string) error {
+	pluginSpec, err := bm.validatePlugin(pluginPath)
+	if err != nil {
+		return err
+	}
+
+	if err := checkDependencies(bm.store, pluginSpec); err != nil {
+		bm.emit(EventLoadFailed, pluginSpec, err)
+		return err
+	}
+
+	return bm.load(pluginSpec)
+}
+
+//validatePlugin extracts the plugin bundle if needed and validates the plugin
+func (bm *BaseManager) validatePlugin(pluginPath string) (pluginSpec *spec.Plugin, err error) {
+	originalPath := pluginPath
+
+	span := bm.activeTracer().Start(SpanValidate, SpanContext{})
+	span.SetAttribute("plugin.path", originalPath)
+	defer func() {
+		if pluginSpec != nil {
+			span.SetAttribute("plugin.name", pluginSpec.Name)
+			span.SetAttribute("plugin.version", pluginSpec.Version)
+		}
+		span.End(err)
+	}()
+
+	//extract the bundle to the cache dir first
+	if pkg.IsBundle(pluginPath) {
+		pluginDir, err := bm.bundles.Extract(pluginPath)
+		if err != nil {
+			bm.logger.Error("Extract plugin bundle [FAILED]", "path", pluginPath, "error", err)
+			bm.emitPath(EventValidationFailed, originalPath, err)
+			return nil, err
+		}
+		bm.logger.Info("Extract plugin bundle [SUCCESS]", "path", pluginPath, "dir", pluginDir)
+		pluginPath = pluginDir
+	}
+
 	//validate
 	validateRes, err := bm.validtor.Validate(pluginPath)
 	if err != nil {
-		log.Printf("[INFO]: Valdate plugin [FAILED]: %s", pluginPath)
-		return err
+		bm.logger.Error("Validate plugin [FAILED]", "path", pluginPath, "error", err)
+		bm.emitPath(EventValidationFailed, originalPath, err)
+		return nil, err
 	}
-	log.Printf("[INFO]: Valdate plugin [SUCCESS]: %s", pluginPath)
+	bm.logger.Info("Validate plugin [SUCCESS]", "path", pluginPath)
 
 	//Convert validate result to plugin spec object
 	pluginSpec, ok := validateRes.(*spec.Plugin)
 	if !ok {
-		log.Println("[ERROR]: Failed to convert validation result to plugin spec")
-		return errors.New("Failed to convert validation result to plugin spec")
+		err := errors.New("Failed to convert validation result to plugin spec")
+		bm.logger.Error(err.Error(), "path", pluginPath)
+		bm.emitPath(EventValidationFailed, originalPath, err)
+		return nil, err
 	}
-	//load
-	exec, err := bm.loader.Load(pluginSpec)
+	pluginSpec.Path = originalPath
+	bm.emit(EventValidated, pluginSpec, nil)
+
+	return pluginSpec, nil
+}
+
+//load the validated plugin, call its 'Init' function and save it to the store
+func (bm *BaseManager) load(pluginSpec *spec.Plugin) error {
+	pluginItem, err := bm.loadItem(pluginSpec)
 	if err != nil {
-		log.Printf("[INFO]: Load plugin [FAILED]: %s:%s", pluginSpec.Name, pluginSpec.Version)
 		return err
 	}
-	log.Printf("[INFO]: Load plugin [SUCCESS]: %s:%s", pluginSpec.Name, pluginSpec.Version)
 
-	//Save
-	bm.store.Put(&spec.PluginItem{
-		Spec:     pluginSpec,
-		Executor: exec,
-	}, true)
+	//Save, the other versions are kept side by side
+	existing, replaced := bm.store.GetVersion(pluginSpec.Name, pluginSpec.Version)
+	bm.store.Put(pluginItem, true)
+	bm.emit(EventLoaded, pluginSpec, nil)
+
+	//Shutdown the replaced one
+	if replaced && existing != pluginItem {
+		bm.retire(existing)
+	}
 
 	return nil
 }
+
+//loadItem loads the validated plugin, calls its 'Init' function and
+//wraps its executors with the execution state
+func (bm *BaseManager) loadItem(pluginSpec *spec.Plugin) (*spec.PluginItem, error) {
+	if err := bm.hooks.callBeforeLoad(pluginSpec); err != nil {
+		bm.logger.Warn("Load plugin [VETOED]", "plugin", pluginSpec.Name, "version", pluginSpec.Version, "error", err)
+		bm.hooks.callLoadFailed(pluginSpec, err)
+		bm.emit(EventLoadFailed, pluginSpec, err)
+		return nil, err
+	}
+
+	//load
+	span := bm.startSpan(SpanOpen, SpanContext{}, pluginSpec)
+	pluginItem, err := bm.loader.Load(pluginSpec)
+	span.End(err)
+	if err != nil {
+		bm.logger.Error("Load plugin [FAILED]", "plugin", pluginSpec.Name, "version", pluginSpec.Version, "error", err)
+		bm.hooks.callLoadFailed(pluginSpec, err)
+		bm.emit(EventLoadFailed, pluginSpec, err)
+		return nil, err
+	}
+	bm.logger.Info("Load plugin [SUCCESS]", "plugin", pluginSpec.Name, "version", pluginSpec.Version)
+
+	//Init, the plugin is not registered if failed.
+	//The init span follows the open span in the same trace.
+	span = bm.startSpan(SpanInit, span.Context(), pluginSpec)
+	err = bm.callLifecycle(pluginItem, initSymbol, pluginItem.Init)
+	span.End(err)
+	if err != nil {
+		bm.logger.Error("Init plugin [FAILED]", "plugin", pluginSpec.Name, "version", pluginSpec.Version, "error", err)
+		bm.hooks.callLoadFailed(pluginSpec, err)
+		bm.emit(EventLoadFailed, pluginSpec, err)
+		return nil, err
+	}
+
+	//The state is shared by all the entries of the plugin
+	state := &executionState{
+		running: newInFlight(),
+		limits:  newLimiter(pluginSpec),
+		breaker: newBreaker(pluginSpec, func(circuit CircuitState) {
+			bm.circuitChanged(pluginSpec, circuit)
+		}),
+	}
+	pluginItem.Executor = bm.wrapExecutor(pluginItem, "", state, pluginItem.Executor)
+	for label, entry := range pluginItem.Entries {
+		pluginItem.Entries[label] = bm.wrapExecutor(pluginItem, label, state, entry)
+	}
+
+	bm.lock.Lock()
+	bm.executions[pluginItem] = state
+	bm.lock.Unlock()
+
+	bm.hooks.callAfterLoad(pluginSpec)
+
+	return pluginItem, nil
+}
+
+//executionState is the execution state of the loaded plugin item shared by its entries
+type executionState struct {
+	//The running executions
+	running *inFlight
+
+	//The concurrency limiter, nil if not limited
+	limits *limiter
+
+	//The circuit breaker, nil if not set
+	breaker *breaker
+
+	//The count of the consecutive crashes
+	crashes int32
+}
+
+//executionOf returns the execution state of the plugin item, nil if it's not loaded
+func (bm *BaseManager) executionOf(pluginItem *spec.PluginItem) *executionState {
+	bm.lock.Lock()
+	defer bm.lock.Unlock()
+
+	return bm.executions[pluginItem]
+}
+
+//wrapExecutor wraps the executor of the plugin entry with the label to attach the
+//plugin logger, collect the metrics, call the hooks, emit the events, enforce the
+//concurrency limits, guard with the quarantine and the circuit breaker, retry the
+//failed executions, trace and count the running executions.
+//The label of the default executor is empty.
+func (bm *BaseManager) wrapExecutor(pluginItem *spec.PluginItem, label string, state *executionState, exec spec.PluginExecutor) spec.PluginExecutor {
+	pluginSpec := pluginItem.Spec
+	exec = bm.withLogger(pluginSpec, exec)
+	exec = bm.metrics.wrap(pluginSpec, label, exec)
+	exec = bm.hooks.wrap(pluginSpec, exec)
+	exec = bm.observe(pluginSpec, exec)
+	exec = state.limits.wrap(exec)
+	exec = bm.guard(pluginItem, state, exec)
+	exec = bm.retry(pluginSpec, label, exec)
+	exec = bm.trace(pluginSpec, label, exec)
+
+	return state.running.wrap(exec)
+}
+
+//withLogger wraps the lifecycle function or the executor to attach the logger
+//tagged with the plugin name and version to the plugin context
+func (bm *BaseManager) withLogger(pluginSpec *spec.Plugin, fn func(context.PluginContext) error) func(context.PluginContext) error {
+	l := bm.logger.With("plugin", pluginSpec.Name, "version", pluginSpec.Version)
+
+	return func(ctx context.PluginContext) error {
+		return fn(context.WithLogger(ctx, l))
+	}
+}
+
+//callLifecycle calls the lifecycle function of the plugin with timeout.
+//If the function is nil, just ignore it.
+func (bm *BaseManager) callLifecycle(pluginItem *spec.PluginItem, stage string, fn spec.LifecycleFunc) error {
+	if fn == nil {
+		return nil
+	}
+
+	timeout := bm.activeLifecycleTimeout()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+
+		done <- bm.withLogger(pluginItem.Spec, fn)(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("%s plugin %s:%s failed: %s", stage, pluginItem.Spec.Name, pluginItem.Spec.Version, err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%s plugin %s:%s timeout after %s", stage, pluginItem.Spec.Name, pluginItem.Spec.Version, timeout)
+	}
+}
+
+//pluginRef formats the plugin name and the version as 'name@version' for messages
+func pluginRef(name string, version string) string {
+	if len(version) == 0 {
+		version = LatestVersion
+	}
+
+	return name + pkg.PluginVersionSeparator + version
+}
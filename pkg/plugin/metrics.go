@@ -0,0 +1,322 @@
+package plugin
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/steven-zou/go-plugin/pkg/context"
+	"github.com/steven-zou/go-plugin/pkg/spec"
+)
+
+//DefaultLatencyBuckets are the default upper bounds (in seconds) of the execution latency histogram
+var DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+//The entry label of the default executor of the plugin
+const defaultEntryLabel = "default"
+
+//The content type of the Prometheus text exposition format
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+//executionKey identifies the entry of the plugin
+type executionKey struct {
+	plugin  string
+	version string
+	entry   string
+}
+
+//pluginKey identifies the version of the plugin
+type pluginKey struct {
+	plugin  string
+	version string
+}
+
+//executionStats keeps the execution metrics of the entry
+type executionStats struct {
+	executions uint64
+	errors     uint64
+	panics     uint64
+
+	//The cumulative counts of the latency buckets
+	buckets []uint64
+
+	//The sum of the latency in seconds
+	sum float64
+}
+
+//Metrics collects the execution metrics of the plugin entries and the counts of
+//loading, unloading and upgrading the plugins. It serves the metrics in the
+//Prometheus text format as a http.Handler.
+type Metrics struct {
+	//internal lock
+	lock *sync.Mutex
+
+	//The upper bounds of the latency histogram
+	buckets []float64
+
+	executions      map[executionKey]*executionStats
+	retries         map[executionKey]uint64
+	inFlight        map[pluginKey]int64
+	loads           map[pluginKey]uint64
+	loadFailures    map[pluginKey]uint64
+	unloads         map[pluginKey]uint64
+	upgrades        map[pluginKey]uint64
+	upgradeFailures map[pluginKey]uint64
+}
+
+//NewMetrics is constructor of Metrics.
+//If the buckets are empty, the DefaultLatencyBuckets are used.
+func NewMetrics(buckets []float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+
+	sorted := make([]float64, len(buckets))
+	copy(sorted, buckets)
+	sort.Float64s(sorted)
+
+	return &Metrics{
+		lock:            new(sync.Mutex),
+		buckets:         sorted,
+		executions:      make(map[executionKey]*executionStats),
+		retries:         make(map[executionKey]uint64),
+		inFlight:        make(map[pluginKey]int64),
+		loads:           make(map[pluginKey]uint64),
+		loadFailures:    make(map[pluginKey]uint64),
+		unloads:         make(map[pluginKey]uint64),
+		upgrades:        make(map[pluginKey]uint64),
+		upgradeFailures: make(map[pluginKey]uint64),
+	}
+}
+
+//wrap the executor of the plugin entry to collect the execution metrics.
+//The panic is counted and re-panicked.
+func (m *Metrics) wrap(plugin *spec.Plugin, entry string, exec spec.PluginExecutor) spec.PluginExecutor {
+	if len(entry) == 0 {
+		entry = defaultEntryLabel
+	}
+	key := executionKey{plugin: plugin.Name, version: plugin.Version, entry: entry}
+	inFlightKey := pluginKey{plugin: plugin.Name, version: plugin.Version}
+
+	return func(ctx context.PluginContext) (err error) {
+		m.lock.Lock()
+		m.inFlight[inFlightKey]++
+		m.lock.Unlock()
+
+		start := time.Now()
+		panicked := true
+		defer func() {
+			m.observe(key, time.Since(start), err, panicked)
+		}()
+
+		err = exec(ctx)
+		panicked = false
+
+		return err
+	}
+}
+
+//observe records the finished execution
+func (m *Metrics) observe(key executionKey, duration time.Duration, err error, panicked bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.inFlight[pluginKey{plugin: key.plugin, version: key.version}]--
+
+	stats, ok := m.executions[key]
+	if !ok {
+		stats = &executionStats{buckets: make([]uint64, len(m.buckets))}
+		m.executions[key] = stats
+	}
+
+	stats.executions++
+	if panicked {
+		stats.panics++
+	}
+	if err != nil || panicked {
+		stats.errors++
+	}
+
+	seconds := duration.Seconds()
+	stats.sum += seconds
+	for i, bound := range m.buckets {
+		if seconds <= bound {
+			stats.buckets[i]++
+		}
+	}
+}
+
+//retried counts the retry of the failed execution of the plugin entry
+func (m *Metrics) retried(plugin *spec.Plugin, entry string) {
+	if len(entry) == 0 {
+		entry = defaultEntryLabel
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.retries[executionKey{plugin: plugin.Name, version: plugin.Version, entry: entry}]++
+}
+
+//count the event of loading, unloading or upgrading the plugin
+func (m *Metrics) count(eventType EventType, plugin *spec.Plugin, err error) {
+	var counter map[pluginKey]uint64
+	switch eventType {
+	case EventLoaded:
+		counter = m.loads
+	case EventLoadFailed:
+		counter = m.loadFailures
+	case EventUnloaded:
+		counter = m.unloads
+	case EventUpgraded:
+		counter = m.upgrades
+		if err != nil {
+			counter = m.upgradeFailures
+		}
+	default:
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	counter[pluginKey{plugin: plugin.Name, version: plugin.Version}]++
+}
+
+//ServeHTTP implements the http.Handler interface
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", metricsContentType)
+	m.WriteTo(w)
+}
+
+//WriteTo writes the metrics in the Prometheus text format
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	//Render with lock held and write without blocking the executions
+	buf := new(bytes.Buffer)
+	m.render(buf)
+
+	return buf.WriteTo(w)
+}
+
+//render the metrics in the Prometheus text format
+func (m *Metrics) render(buf *bytes.Buffer) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	keys := sortedExecutionKeys(m.executions)
+
+	executionCounters := []struct {
+		name  string
+		help  string
+		value func(*executionStats) uint64
+	}{
+		{"go_plugin_executions_total", "Total executions of the plugin entries.", func(s *executionStats) uint64 { return s.executions }},
+		{"go_plugin_execution_errors_total", "Total executions of the plugin entries returning an error or panicking.", func(s *executionStats) uint64 { return s.errors }},
+		{"go_plugin_execution_panics_total", "Total executions of the plugin entries panicking.", func(s *executionStats) uint64 { return s.panics }},
+	}
+	for _, c := range executionCounters {
+		fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+		for _, key := range keys {
+			fmt.Fprintf(buf, "%s{%s} %d\n", c.name, key.labels(), c.value(m.executions[key]))
+		}
+	}
+
+	const retries = "go_plugin_execution_retries_total"
+	fmt.Fprintf(buf, "# HELP %s Total retries of the failed executions of the plugin entries.\n# TYPE %s counter\n", retries, retries)
+	for _, key := range sortedExecutionKeys(m.retries) {
+		fmt.Fprintf(buf, "%s{%s} %d\n", retries, key.labels(), m.retries[key])
+	}
+
+	const histogram = "go_plugin_execution_duration_seconds"
+	fmt.Fprintf(buf, "# HELP %s Latency of the plugin entry executions.\n# TYPE %s histogram\n", histogram, histogram)
+	for _, key := range keys {
+		stats := m.executions[key]
+		for i, bound := range m.buckets {
+			fmt.Fprintf(buf, "%s_bucket{%s,le=\"%s\"} %d\n", histogram, key.labels(), formatFloat(bound), stats.buckets[i])
+		}
+		fmt.Fprintf(buf, "%s_bucket{%s,le=\"+Inf\"} %d\n", histogram, key.labels(), stats.executions)
+		fmt.Fprintf(buf, "%s_sum{%s} %s\n", histogram, key.labels(), formatFloat(stats.sum))
+		fmt.Fprintf(buf, "%s_count{%s} %d\n", histogram, key.labels(), stats.executions)
+	}
+
+	const gauge = "go_plugin_executions_in_flight"
+	fmt.Fprintf(buf, "# HELP %s Running executions of the plugin.\n# TYPE %s gauge\n", gauge, gauge)
+	for _, key := range sortedPluginKeys(m.inFlight) {
+		fmt.Fprintf(buf, "%s{%s} %d\n", gauge, key.labels(), m.inFlight[key])
+	}
+
+	pluginCounters := []struct {
+		name    string
+		help    string
+		counter map[pluginKey]uint64
+	}{
+		{"go_plugin_loads_total", "Total successful loads of the plugin.", m.loads},
+		{"go_plugin_load_failures_total", "Total failed loads of the plugin.", m.loadFailures},
+		{"go_plugin_unloads_total", "Total unloads of the plugin.", m.unloads},
+		{"go_plugin_upgrades_total", "Total successful upgrades to the plugin version.", m.upgrades},
+		{"go_plugin_upgrade_failures_total", "Total failed or rolled back upgrades to the plugin version.", m.upgradeFailures},
+	}
+	for _, c := range pluginCounters {
+		fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+		for _, key := range sortedPluginKeys(c.counter) {
+			fmt.Fprintf(buf, "%s{%s} %d\n", c.name, key.labels(), c.counter[key])
+		}
+	}
+}
+
+func (key executionKey) labels() string {
+	return fmt.Sprintf("plugin=\"%s\",version=\"%s\",entry=\"%s\"", escapeLabel(key.plugin), escapeLabel(key.version), escapeLabel(key.entry))
+}
+
+func (key pluginKey) labels() string {
+	return fmt.Sprintf("plugin=\"%s\",version=\"%s\"", escapeLabel(key.plugin), escapeLabel(key.version))
+}
+
+func sortedExecutionKeys[V any](m map[executionKey]V) []executionKey {
+	keys := make([]executionKey, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.plugin != b.plugin {
+			return a.plugin < b.plugin
+		}
+		if a.version != b.version {
+			return a.version < b.version
+		}
+		return a.entry < b.entry
+	})
+
+	return keys
+}
+
+func sortedPluginKeys[V any](m map[pluginKey]V) []pluginKey {
+	keys := make([]pluginKey, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].plugin != keys[j].plugin {
+			return keys[i].plugin < keys[j].plugin
+		}
+		return keys[i].version < keys[j].version
+	})
+
+	return keys
+}
+
+//escapeLabel escapes the label value with the rules of the Prometheus text format
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
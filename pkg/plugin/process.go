@@ -0,0 +1,366 @@
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/steven-zou/go-plugin/pkg/context"
+	"github.com/steven-zou/go-plugin/pkg/logger"
+	"github.com/steven-zou/go-plugin/pkg/process"
+	"github.com/steven-zou/go-plugin/pkg/spec"
+)
+
+const (
+	//The min and max delay before restarting the crashed plugin process
+	processMinRestartDelay = 100 * time.Millisecond
+	processMaxRestartDelay = 30 * time.Second
+
+	//The grace period for the plugin process to exit after the stdin is closed
+	processStopTimeout = 5 * time.Second
+)
+
+//ErrProcessStopped is returned when calling the stopped plugin process
+var ErrProcessStopped = errors.New("plugin process is stopped")
+
+//ProcessExitedError is returned when the plugin process exits while handling the call
+type ProcessExitedError struct {
+	//Name of the plugin
+	Plugin string
+
+	//The exit error of the process
+	Err error
+}
+
+//Error implements the error interface
+func (pe *ProcessExitedError) Error() string {
+	return fmt.Sprintf("plugin process %s exited: %v", pe.Plugin, pe.Err)
+}
+
+//processClient launches the plugin executable as a child process and
+//calls it over the stdio. The child process is restarted if crashed.
+type processClient struct {
+	//Name of the plugin
+	name string
+
+	//Path of the plugin executable
+	path string
+
+	//Log the restarts and the malformed responses
+	logger logger.Logger
+
+	//internal lock
+	lock *sync.Mutex
+
+	//The running child process
+	cmd *exec.Cmd
+
+	//The stdin of the child process
+	stdin io.WriteCloser
+
+	//Encode the requests to the stdin
+	encoder *json.Encoder
+
+	//The waiting requests
+	pending map[uint64]chan *process.Response
+
+	//The ID of the next request
+	nextID uint64
+
+	//Whether the client is stopped
+	stopped bool
+
+	//The delay before next restarting
+	restartDelay time.Duration
+
+	//Closed when the current child process exits
+	exited chan struct{}
+
+	//The exit error of the last child process
+	exitErr error
+}
+
+func newProcessClient(name string, path string, l logger.Logger) *processClient {
+	return &processClient{
+		name:         name,
+		path:         path,
+		logger:       l,
+		lock:         new(sync.Mutex),
+		pending:      make(map[uint64]chan *process.Response),
+		restartDelay: processMinRestartDelay,
+	}
+}
+
+//start launches the child process, should be called with lock held
+func (pc *processClient) start() error {
+	cmd := exec.Command(pc.path)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start plugin process %s: %s", pc.path, err)
+	}
+
+	pc.cmd = cmd
+	pc.stdin = stdin
+	pc.encoder = json.NewEncoder(stdin)
+	pc.exited = make(chan struct{})
+
+	go pc.receive(cmd, stdout, pc.exited)
+
+	return nil
+}
+
+//receive dispatches the responses until the child process exits
+func (pc *processClient) receive(cmd *exec.Cmd, stdout io.Reader, exited chan struct{}) {
+	decoder := json.NewDecoder(stdout)
+	for {
+		resp := &process.Response{}
+		if err := decoder.Decode(resp); err != nil {
+			if err != io.EOF {
+				//Broken protocol, the child process is not usable
+				pc.logger.Error("Plugin process sent malformed response", "plugin", pc.name, "error", err)
+				cmd.Process.Kill()
+			}
+			break
+		}
+
+		pc.lock.Lock()
+		//The child process works well, reset the restart delay
+		pc.restartDelay = processMinRestartDelay
+		if ch, ok := pc.pending[resp.ID]; ok {
+			delete(pc.pending, resp.ID)
+			ch <- resp
+		}
+		pc.lock.Unlock()
+	}
+
+	err := cmd.Wait()
+	close(exited)
+
+	pc.lock.Lock()
+	defer pc.lock.Unlock()
+
+	//Fail all the waiting requests
+	pc.exitErr = err
+	for id, ch := range pc.pending {
+		delete(pc.pending, id)
+		close(ch)
+	}
+
+	if pc.stopped {
+		return
+	}
+
+	pc.logger.Error("Plugin process exited unexpectedly", "plugin", pc.name, "error", err, "restart_after", pc.restartDelay)
+	delay := pc.restartDelay
+	pc.restartDelay *= 2
+	if pc.restartDelay > processMaxRestartDelay {
+		pc.restartDelay = processMaxRestartDelay
+	}
+	pc.cmd = nil
+
+	time.AfterFunc(delay, pc.restart)
+}
+
+//restart the crashed child process and call its 'Init' handler again
+func (pc *processClient) restart() {
+	pc.lock.Lock()
+	if pc.stopped || pc.cmd != nil {
+		pc.lock.Unlock()
+		return
+	}
+
+	if err := pc.start(); err != nil {
+		pc.logger.Error("Restart plugin process error", "plugin", pc.name, "error", err)
+		delay := pc.restartDelay
+		pc.restartDelay *= 2
+		if pc.restartDelay > processMaxRestartDelay {
+			pc.restartDelay = processMaxRestartDelay
+		}
+		time.AfterFunc(delay, pc.restart)
+		pc.lock.Unlock()
+		return
+	}
+	pc.lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultLifecycleTimeout)
+	defer cancel()
+	if err := pc.call(ctx, process.MethodInit, ""); err != nil {
+		pc.logger.Error("Init restarted plugin process error", "plugin", pc.name, "error", err)
+		return
+	}
+
+	pc.logger.Info("Plugin process restarted", "plugin", pc.name)
+}
+
+//call sends the request to the child process and waits for the response.
+//The cancellation of the context is forwarded to the child process.
+func (pc *processClient) call(ctx context.PluginContext, method string, entry string) error {
+	pc.lock.Lock()
+	if pc.stopped {
+		pc.lock.Unlock()
+		return ErrProcessStopped
+	}
+	if pc.cmd == nil {
+		pc.lock.Unlock()
+		return fmt.Errorf("plugin process %s is restarting", pc.name)
+	}
+
+	pc.nextID++
+	req := &process.Request{
+		ID:     pc.nextID,
+		Method: method,
+		Entry:  entry,
+		Values: process.EncodeValues(context.Values(ctx)),
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Deadline = &deadline
+	}
+
+	ch := make(chan *process.Response, 1)
+	pc.pending[req.ID] = ch
+	if err := pc.encoder.Encode(req); err != nil {
+		delete(pc.pending, req.ID)
+		pc.lock.Unlock()
+		return fmt.Errorf("failed to call plugin process %s: %s", pc.name, err)
+	}
+	pc.lock.Unlock()
+
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			pc.lock.Lock()
+			err := pc.exitErr
+			pc.lock.Unlock()
+
+			return &ProcessExitedError{Plugin: pc.name, Err: err}
+		}
+
+		values, err := process.DecodeValues(resp.Values)
+		if err != nil {
+			return err
+		}
+		for k, v := range values {
+			ctx.SetValue(k, v)
+		}
+
+		if len(resp.Error) > 0 {
+			if resp.Crashed {
+				return &CrashedError{Plugin: pc.name, Err: errors.New(resp.Error)}
+			}
+			if resp.NonRetryable {
+				return context.NonRetryable(errors.New(resp.Error))
+			}
+			return errors.New(resp.Error)
+		}
+		return nil
+	case <-ctx.Done():
+		pc.lock.Lock()
+		if _, ok := pc.pending[req.ID]; ok {
+			delete(pc.pending, req.ID)
+			pc.encoder.Encode(&process.Request{ID: req.ID, Method: process.MethodCancel})
+		}
+		pc.lock.Unlock()
+
+		return ctx.Err()
+	}
+}
+
+//executor returns the executor calling the entry of the child process
+func (pc *processClient) executor(entry string) spec.PluginExecutor {
+	return func(ctx context.PluginContext) error {
+		return pc.call(ctx, process.MethodExecute, entry)
+	}
+}
+
+//shutdown calls the 'Shutdown' handler and stops the child process
+func (pc *processClient) shutdown(ctx context.PluginContext) error {
+	err := pc.call(ctx, process.MethodShutdown, "")
+	pc.stop()
+
+	return err
+}
+
+//stop the child process by closing its stdin, kill it if not exit in time
+func (pc *processClient) stop() {
+	pc.lock.Lock()
+	if pc.stopped {
+		pc.lock.Unlock()
+		return
+	}
+	pc.stopped = true
+
+	cmd, exited := pc.cmd, pc.exited
+	if cmd != nil {
+		pc.stdin.Close()
+	}
+	pc.lock.Unlock()
+
+	if cmd == nil {
+		return
+	}
+
+	select {
+	case <-exited:
+	case <-time.After(processStopTimeout):
+		cmd.Process.Kill()
+	}
+}
+
+//loadProcess launches the plugin executable and builds the plugin item
+func loadProcess(plugin *spec.Plugin, l logger.Logger) (*spec.PluginItem, error) {
+	pc := newProcessClient(plugin.Name, plugin.Source.Path, l)
+
+	pc.lock.Lock()
+	err := pc.start()
+	pc.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	item := &spec.PluginItem{
+		Spec:    plugin,
+		Entries: make(map[string]spec.PluginExecutor),
+		Init: func(ctx context.PluginContext) error {
+			err := pc.call(ctx, process.MethodInit, "")
+			if err != nil {
+				//The plugin will not be registered
+				pc.stop()
+			}
+
+			return err
+		},
+		Shutdown: pc.shutdown,
+		Health: func(ctx context.PluginContext) error {
+			return pc.call(ctx, process.MethodHealth, "")
+		},
+	}
+
+	for label, entry := range plugin.Entries {
+		item.Entries[label] = pc.executor(entry)
+	}
+
+	err = resolveExecutor(item, func() (spec.PluginExecutor, error) {
+		return pc.executor(process.DefaultEntry), nil
+	})
+	if err != nil {
+		pc.stop()
+		return nil, err
+	}
+
+	return item, nil
+}
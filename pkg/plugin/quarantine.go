@@ -0,0 +1,215 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/steven-zou/go-plugin/pkg/context"
+	"github.com/steven-zou/go-plugin/pkg/spec"
+)
+
+//DefaultQuarantineThreshold is the default consecutive crashes to quarantine the plugin
+const DefaultQuarantineThreshold = 5
+
+//errPanicked is recorded for the panicking executions
+var errPanicked = errors.New("plugin execution panicked")
+
+//CrashedError is returned when the process plugin panics or the wasm plugin
+//traps or panics in the call
+type CrashedError struct {
+	//Name of the plugin
+	Plugin string
+
+	//The panic or the trap
+	Err error
+}
+
+//Error implements the error interface
+func (ce *CrashedError) Error() string {
+	return fmt.Sprintf("plugin %s crashed: %v", ce.Plugin, ce.Err)
+}
+
+//QuarantinedError is returned when executing the quarantined plugin
+type QuarantinedError struct {
+	//Name of the plugin
+	Plugin string
+
+	//Version of the plugin
+	Version string
+
+	//Why the plugin is quarantined
+	Reason string
+
+	//When the plugin is quarantined
+	Since time.Time
+}
+
+//Error implements the error interface
+func (qe *QuarantinedError) Error() string {
+	return fmt.Sprintf("plugin %s:%s is quarantined since %s: %s", qe.Plugin, qe.Version, qe.Since.Format(time.RFC3339), qe.Reason)
+}
+
+//SetQuarantineThreshold implements the interface method
+func (bm *BaseManager) SetQuarantineThreshold(crashes int) {
+	if crashes > math.MaxInt32 {
+		crashes = math.MaxInt32
+	}
+
+	atomic.StoreInt32(&bm.quarantineThreshold, int32(crashes))
+}
+
+//QuarantinePlugin implements the interface method
+func (bm *BaseManager) QuarantinePlugin(name string, version string, reason string) error {
+	pluginItem, ok := bm.store.GetVersion(name, version)
+	if !ok {
+		return fmt.Errorf("plugin '%s' is not existing", pluginRef(name, version))
+	}
+
+	bm.quarantine(pluginItem, reason)
+
+	return nil
+}
+
+//EnablePlugin implements the interface method
+func (bm *BaseManager) EnablePlugin(name string, version string) error {
+	pluginItem, ok := bm.store.GetVersion(name, version)
+	if !ok {
+		return fmt.Errorf("plugin '%s' is not existing", pluginRef(name, version))
+	}
+
+	if !bm.store.Release(pluginItem) {
+		return fmt.Errorf("plugin %s:%s is not quarantined", pluginItem.Spec.Name, pluginItem.Spec.Version)
+	}
+	if state := bm.executionOf(pluginItem); state != nil {
+		atomic.StoreInt32(&state.crashes, 0)
+	}
+
+	bm.logger.Info("Enable plugin [SUCCESS]", "plugin", pluginItem.Spec.Name, "version", pluginItem.Spec.Version)
+	bm.emit(EventEnabled, pluginItem.Spec, nil)
+
+	return nil
+}
+
+//GetQuarantine implements the interface method
+func (bm *BaseManager) GetQuarantine(name string, version string) (*Quarantine, error) {
+	pluginItem, ok := bm.store.GetVersion(name, version)
+	if !ok {
+		return nil, fmt.Errorf("plugin '%s' is not existing", pluginRef(name, version))
+	}
+
+	return bm.store.Quarantined(pluginItem), nil
+}
+
+//GetCircuitState implements the interface method
+func (bm *BaseManager) GetCircuitState(name string, version string) (CircuitState, error) {
+	pluginItem, ok := bm.store.GetVersion(name, version)
+	if !ok {
+		return "", fmt.Errorf("plugin '%s' is not existing", pluginRef(name, version))
+	}
+
+	if state := bm.executionOf(pluginItem); state != nil && state.breaker != nil {
+		return state.breaker.current(), nil
+	}
+
+	return CircuitClosed, nil
+}
+
+//quarantine the plugin item with the reason
+func (bm *BaseManager) quarantine(pluginItem *spec.PluginItem, reason string) {
+	if !bm.store.Quarantine(pluginItem, reason) {
+		return
+	}
+
+	bm.logger.Warn("Plugin is quarantined", "plugin", pluginItem.Spec.Name, "version", pluginItem.Spec.Version, "reason", reason)
+	bm.emit(EventQuarantined, pluginItem.Spec, errors.New(reason))
+}
+
+//guard wraps the executor to reject the executions of the quarantined plugin
+//and the ones rejected by the circuit breaker, and counts the crashes
+//(panicking or the plugin process exiting) to quarantine the plugin.
+func (bm *BaseManager) guard(pluginItem *spec.PluginItem, state *executionState, exec spec.PluginExecutor) spec.PluginExecutor {
+	return func(ctx context.PluginContext) (err error) {
+		if q := bm.store.Quarantined(pluginItem); q != nil {
+			return &QuarantinedError{
+				Plugin:  pluginItem.Spec.Name,
+				Version: pluginItem.Spec.Version,
+				Reason:  q.Reason,
+				Since:   q.Time,
+			}
+		}
+
+		if state.breaker != nil {
+			if err := state.breaker.allow(); err != nil {
+				return err
+			}
+		}
+
+		panicked := true
+		defer func() {
+			result := err
+			if panicked {
+				result = errPanicked
+			}
+
+			if state.breaker != nil {
+				state.breaker.record(result)
+			}
+			bm.countCrash(pluginItem, state, panicked, result)
+		}()
+
+		err = exec(ctx)
+		panicked = false
+
+		return err
+	}
+}
+
+//countCrash counts the consecutive crashes of the plugin item and
+//quarantines it once the threshold is reached
+func (bm *BaseManager) countCrash(pluginItem *spec.PluginItem, state *executionState, panicked bool, err error) {
+	var (
+		exited     *ProcessExitedError
+		crashed    *CrashedError
+		overloaded *OverloadedError
+		veto       *HookVetoError
+	)
+	if !panicked && (errors.As(err, &overloaded) || errors.As(err, &veto)) {
+		//Not executed
+		return
+	}
+	if !panicked && !errors.As(err, &exited) && !errors.As(err, &crashed) {
+		atomic.StoreInt32(&state.crashes, 0)
+		return
+	}
+
+	crashes := atomic.AddInt32(&state.crashes, 1)
+	threshold := atomic.LoadInt32(&bm.quarantineThreshold)
+	if threshold <= 0 || crashes < threshold {
+		return
+	}
+
+	atomic.StoreInt32(&state.crashes, 0)
+	if bm.store.Quarantined(pluginItem) == nil {
+		bm.quarantine(pluginItem, fmt.Sprintf("crashed %d times in a row, last: %s", crashes, err))
+	}
+}
+
+//circuitChanged reports the state change of the circuit breaker of the plugin
+func (bm *BaseManager) circuitChanged(plugin *spec.Plugin, circuit CircuitState) {
+	eventType := EventCircuitClosed
+	switch circuit {
+	case CircuitOpen:
+		eventType = EventCircuitOpened
+		bm.logger.Warn("Plugin circuit is open", "plugin", plugin.Name, "version", plugin.Version)
+	case CircuitHalfOpen:
+		eventType = EventCircuitHalfOpen
+		bm.logger.Info("Plugin circuit is half-open", "plugin", plugin.Name, "version", plugin.Version)
+	default:
+		bm.logger.Info("Plugin circuit is closed", "plugin", plugin.Name, "version", plugin.Version)
+	}
+
+	bm.emit(eventType, plugin, nil)
+}
@@ -0,0 +1,92 @@
+package plugin
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/steven-zou/go-plugin/pkg/logger"
+)
+
+func TestCountCrash(t *testing.T) {
+	exited := &ProcessExitedError{Plugin: "billing", Err: errors.New("exit status 2")}
+	crashed := &CrashedError{Plugin: "billing", Err: errors.New("trapped")}
+	overloaded := &OverloadedError{Plugin: "billing", Version: "1.0.0"}
+	veto := &HookVetoError{Hook: hookBeforeExecute, Plugin: "billing", Version: "1.0.0", Err: errors.New("denied")}
+
+	cases := []struct {
+		name string
+		//The results of the executions, nil means the panic
+		results     []error
+		quarantined bool
+	}{
+		{"panics in a row", []error{nil, nil}, true},
+		{"process exits in a row", []error{exited, exited}, true},
+		{"process or wasm crashes in a row", []error{crashed, crashed}, true},
+		{"reset by the execution not crashing", []error{nil, errTestFailure, nil}, false},
+		{"not reset by the overloading", []error{crashed, overloaded, crashed}, true},
+		{"not reset by the veto", []error{crashed, veto, crashed}, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			bm := NewBaseManager().(*BaseManager)
+			bm.SetLogger(logger.Discard)
+			bm.SetQuarantineThreshold(2)
+
+			pluginItem := newTestItem("billing", "1.0.0")
+			bm.store.Put(pluginItem, false)
+			state := &executionState{}
+
+			for _, err := range c.results {
+				if err == nil {
+					bm.countCrash(pluginItem, state, true, errPanicked)
+					continue
+				}
+				bm.countCrash(pluginItem, state, false, err)
+			}
+
+			if q := bm.store.Quarantined(pluginItem); (q != nil) != c.quarantined {
+				t.Fatalf("expect quarantined %v but got %v", c.quarantined, q)
+			}
+		})
+	}
+}
+
+func TestSetQuarantineThreshold(t *testing.T) {
+	bm := NewBaseManager().(*BaseManager)
+	bm.SetLogger(logger.Discard)
+
+	pluginItem := newTestItem("billing", "1.0.0")
+	bm.store.Put(pluginItem, false)
+	state := &executionState{}
+
+	//Changed while counting the crashes
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			bm.SetQuarantineThreshold(0)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		bm.countCrash(pluginItem, state, true, errPanicked)
+	}
+	wg.Wait()
+
+	//Quarantined with the default threshold before it's changed
+	if q := bm.store.Quarantined(pluginItem); q != nil {
+		if err := bm.EnablePlugin("billing", "1.0.0"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//Disabled by the zero threshold
+	for i := 0; i < DefaultQuarantineThreshold*2; i++ {
+		bm.countCrash(pluginItem, state, true, errPanicked)
+	}
+	if q := bm.store.Quarantined(pluginItem); q != nil {
+		t.Fatalf("expect no quarantine with the zero threshold but got %s", q.Reason)
+	}
+}
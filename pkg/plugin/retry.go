@@ -0,0 +1,186 @@
+package plugin
+
+import (
+	sys_context "context"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/steven-zou/go-plugin/pkg/context"
+	"github.com/steven-zou/go-plugin/pkg/spec"
+)
+
+//The classes of the retryable errors
+const (
+	//RetryOnError retries the errors returned by the plugin
+	RetryOnError = "error"
+
+	//RetryOnTimeout retries the deadline exceeded errors returned by the plugin while its
+	//context is not done, e.g: the timeout of its backend call. The execution exceeding
+	//the deadline of the plugin context itself is not retried as there is no time left.
+	RetryOnTimeout = "timeout"
+
+	//RetryOnOverloaded retries the executions rejected by the concurrency limits
+	RetryOnOverloaded = "overloaded"
+
+	//RetryOnCrash retries the executions failed by the plugin process exiting,
+	//or the process or wasm plugin crashing
+	RetryOnCrash = "crash"
+)
+
+const (
+	//DefaultRetryInitialBackoff is the default backoff before the first retry
+	DefaultRetryInitialBackoff = 100 * time.Millisecond
+
+	//DefaultRetryMaxBackoff is the default max backoff between the retries
+	DefaultRetryMaxBackoff = 10 * time.Second
+
+	//DefaultRetryMultiplier is the default factor to grow the backoff
+	DefaultRetryMultiplier = 2.0
+
+	//DefaultRetryJitter is the default fraction of the backoff to randomize
+	DefaultRetryJitter = 0.2
+)
+
+//DefaultRetryOn are the default classes of the retryable errors
+var DefaultRetryOn = []string{RetryOnError, RetryOnTimeout}
+
+//retryPolicy is the parsed retry settings of the plugin
+type retryPolicy struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	multiplier     float64
+	jitter         float64
+	retryOn        map[string]bool
+}
+
+//newRetryPolicy parses the retry settings of the plugin,
+//nil is returned if the plugin is not retried
+func newRetryPolicy(plugin *spec.Plugin) *retryPolicy {
+	r := plugin.Retry
+	if r == nil || r.MaxAttempts <= 1 {
+		return nil
+	}
+
+	p := &retryPolicy{
+		maxAttempts:    r.MaxAttempts,
+		initialBackoff: DefaultRetryInitialBackoff,
+		maxBackoff:     DefaultRetryMaxBackoff,
+		multiplier:     DefaultRetryMultiplier,
+		jitter:         DefaultRetryJitter,
+		retryOn:        make(map[string]bool),
+	}
+	if d, err := time.ParseDuration(r.InitialBackoff); err == nil && d > 0 {
+		p.initialBackoff = d
+	}
+	if d, err := time.ParseDuration(r.MaxBackoff); err == nil && d > 0 {
+		p.maxBackoff = d
+	}
+	if r.Multiplier >= 1 {
+		p.multiplier = r.Multiplier
+	}
+	if r.Jitter != nil {
+		p.jitter = *r.Jitter
+	}
+
+	retryOn := r.RetryOn
+	if len(retryOn) == 0 {
+		retryOn = DefaultRetryOn
+	}
+	for _, class := range retryOn {
+		p.retryOn[class] = true
+	}
+
+	return p
+}
+
+//retryable checks if the execution failed with the error can be retried
+func (p *retryPolicy) retryable(ctx context.PluginContext, err error) bool {
+	//Not retried if the caller gives up or the execution deadline is reached
+	if err == nil || context.IsNonRetryable(err) || ctx.Err() != nil {
+		return false
+	}
+
+	var (
+		overloaded  *OverloadedError
+		exited      *ProcessExitedError
+		crashed     *CrashedError
+		circuitOpen *CircuitOpenError
+		quarantined *QuarantinedError
+		veto        *HookVetoError
+	)
+	switch {
+	case errors.As(err, &circuitOpen), errors.As(err, &quarantined), errors.As(err, &veto), errors.Is(err, sys_context.Canceled):
+		return false
+	case errors.As(err, &overloaded):
+		return p.retryOn[RetryOnOverloaded]
+	case errors.As(err, &exited), errors.As(err, &crashed):
+		return p.retryOn[RetryOnCrash]
+	case errors.Is(err, sys_context.DeadlineExceeded):
+		return p.retryOn[RetryOnTimeout]
+	default:
+		return p.retryOn[RetryOnError]
+	}
+}
+
+//backoff returns the backoff before the retry after the attempt (starting from 1)
+func (p *retryPolicy) backoff(attempt int) time.Duration {
+	backoff := float64(p.initialBackoff) * math.Pow(p.multiplier, float64(attempt-1))
+	if backoff > float64(p.maxBackoff) {
+		backoff = float64(p.maxBackoff)
+	}
+
+	//Randomize in [backoff*(1-jitter), backoff]
+	backoff -= backoff * p.jitter * rand.Float64()
+
+	return time.Duration(backoff)
+}
+
+//retry wraps the executor of the plugin entry to retry the failed executions with
+//the retry policy of the plugin. The attempt number is set to the plugin context,
+//and the retries are sent as the 'retrying' events and counted in the metrics.
+func (bm *BaseManager) retry(plugin *spec.Plugin, label string, exec spec.PluginExecutor) spec.PluginExecutor {
+	policy := newRetryPolicy(plugin)
+	if policy == nil {
+		return exec
+	}
+
+	return func(ctx context.PluginContext) error {
+		previous := ctx.GetValue(context.AttemptKey)
+		defer restoreValue(ctx, context.AttemptKey, previous)
+
+		for attempt := 1; ; attempt++ {
+			ctx.SetValue(context.AttemptKey, attempt)
+
+			err := exec(ctx)
+			if attempt >= policy.maxAttempts || !policy.retryable(ctx, err) {
+				return err
+			}
+
+			backoff := policy.backoff(attempt)
+			bm.logger.Debug("Retry plugin execution", "plugin", plugin.Name, "version", plugin.Version, "attempt", attempt, "backoff", backoff, "error", err)
+			bm.metrics.retried(plugin, label)
+			bm.events.publish(&Event{
+				Type:        EventRetrying,
+				Name:        plugin.Name,
+				Version:     plugin.Version,
+				Path:        plugin.Path,
+				Time:        time.Now(),
+				Duration:    backoff,
+				ExecutionID: stringValue(ctx, context.ExecutionIDKey),
+				Attempt:     attempt,
+				Err:         err,
+			})
+
+			timer := time.NewTimer(backoff)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package plugin
+
+import (
+	sys_context "context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/steven-zou/go-plugin/pkg/context"
+	"github.com/steven-zou/go-plugin/pkg/logger"
+	"github.com/steven-zou/go-plugin/pkg/spec"
+)
+
+func TestNewRetryPolicy(t *testing.T) {
+	for _, r := range []*spec.Retry{nil, {MaxAttempts: 1}} {
+		if p := newRetryPolicy(&spec.Plugin{Name: "billing", Retry: r}); p != nil {
+			t.Errorf("expect no retry policy with %+v", r)
+		}
+	}
+
+	p := newRetryPolicy(&spec.Plugin{Name: "billing", Retry: &spec.Retry{MaxAttempts: 3, InitialBackoff: "invalid", Multiplier: 0.5}})
+	if p.initialBackoff != DefaultRetryInitialBackoff || p.maxBackoff != DefaultRetryMaxBackoff {
+		t.Errorf("expect default backoffs but got %s and %s", p.initialBackoff, p.maxBackoff)
+	}
+	if p.multiplier != DefaultRetryMultiplier || p.jitter != DefaultRetryJitter {
+		t.Errorf("expect default multiplier and jitter but got %v and %v", p.multiplier, p.jitter)
+	}
+	for _, class := range DefaultRetryOn {
+		if !p.retryOn[class] {
+			t.Errorf("expect %s retried by default", class)
+		}
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	errFailed := errors.New("failed")
+	overloaded := &OverloadedError{Plugin: "billing", Version: "1.0.0"}
+	exited := &ProcessExitedError{Plugin: "billing", Err: errFailed}
+	crashed := &CrashedError{Plugin: "billing", Err: errFailed}
+	veto := &HookVetoError{Hook: hookBeforeExecute, Plugin: "billing", Version: "1.0.0", Err: errFailed}
+
+	cases := []struct {
+		name      string
+		retryOn   []string
+		ctx       context.PluginContext
+		err       error
+		retryable bool
+	}{
+		{"no error", nil, nil, nil, false},
+		{"error", nil, nil, errFailed, true},
+		{"wrapped error", nil, nil, fmt.Errorf("call: %w", errFailed), true},
+		{"error not in retry on", []string{RetryOnTimeout}, nil, errFailed, false},
+		{"non-retryable", nil, nil, context.NonRetryable(errFailed), false},
+		{"context done", nil, canceled, errFailed, false},
+		{"canceled", []string{RetryOnError}, nil, sys_context.Canceled, false},
+		{"timeout", nil, nil, sys_context.DeadlineExceeded, true},
+		{"timeout not in retry on", []string{RetryOnError}, nil, sys_context.DeadlineExceeded, false},
+		{"overloaded", []string{RetryOnOverloaded}, nil, overloaded, true},
+		{"overloaded not in retry on", nil, nil, overloaded, false},
+		{"crash", []string{RetryOnCrash}, nil, exited, true},
+		{"crash not in retry on", nil, nil, exited, false},
+		{"crashed", []string{RetryOnCrash}, nil, crashed, true},
+		{"crashed not in retry on", nil, nil, crashed, false},
+		{"veto", []string{RetryOnError}, nil, veto, false},
+		{"circuit open", []string{RetryOnError}, nil, &CircuitOpenError{Plugin: "billing"}, false},
+		{"quarantined", []string{RetryOnError}, nil, &QuarantinedError{Plugin: "billing"}, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := newRetryPolicy(&spec.Plugin{Name: "billing", Retry: &spec.Retry{MaxAttempts: 3, RetryOn: c.retryOn}})
+
+			ctx := c.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			if got := p.retryable(ctx, c.err); got != c.retryable {
+				t.Errorf("expect retryable %v but got %v", c.retryable, got)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	noJitter, jitter := 0.0, 0.5
+
+	cases := []struct {
+		name    string
+		retry   *spec.Retry
+		attempt int
+		min     time.Duration
+		max     time.Duration
+	}{
+		{"first", &spec.Retry{InitialBackoff: "100ms", Jitter: &noJitter}, 1, 100 * time.Millisecond, 100 * time.Millisecond},
+		{"grows", &spec.Retry{InitialBackoff: "100ms", Jitter: &noJitter}, 3, 400 * time.Millisecond, 400 * time.Millisecond},
+		{"multiplier", &spec.Retry{InitialBackoff: "100ms", Multiplier: 3, Jitter: &noJitter}, 3, 900 * time.Millisecond, 900 * time.Millisecond},
+		{"max backoff", &spec.Retry{InitialBackoff: "100ms", MaxBackoff: "1s", Jitter: &noJitter}, 10, time.Second, time.Second},
+		{"large attempt", &spec.Retry{InitialBackoff: "100ms", MaxBackoff: "1s", Jitter: &noJitter}, 10000, time.Second, time.Second},
+		{"jitter", &spec.Retry{InitialBackoff: "100ms", Jitter: &jitter}, 2, 100 * time.Millisecond, 200 * time.Millisecond},
+		{"jitter of max backoff", &spec.Retry{InitialBackoff: "100ms", MaxBackoff: "1s", Jitter: &jitter}, 10, 500 * time.Millisecond, time.Second},
+		{"default jitter", &spec.Retry{InitialBackoff: "100ms"}, 1, 80 * time.Millisecond, 100 * time.Millisecond},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.retry.MaxAttempts = 3
+			p := newRetryPolicy(&spec.Plugin{Name: "billing", Retry: c.retry})
+
+			//Randomized by the jitter
+			for i := 0; i < 100; i++ {
+				if backoff := p.backoff(c.attempt); backoff < c.min || backoff > c.max {
+					t.Fatalf("expect backoff in [%s, %s] but got %s", c.min, c.max, backoff)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryDeadline(t *testing.T) {
+	bm := NewBaseManager().(*BaseManager)
+	bm.SetLogger(logger.Discard)
+
+	cases := []struct {
+		name     string
+		retryOn  []string
+		backend  bool
+		attempts int
+	}{
+		{"deadline of the context", nil, false, 1},
+		{"deadline of the backend call", nil, true, 3},
+		{"deadline of the backend call not in retry on", []string{RetryOnError}, true, 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			noJitter := 0.0
+			plugin := &spec.Plugin{
+				Name:    "billing",
+				Version: "1.0.0",
+				Retry:   &spec.Retry{MaxAttempts: 3, InitialBackoff: "1ms", Jitter: &noJitter, RetryOn: c.retryOn},
+			}
+
+			attempts := 0
+			exec := bm.retry(plugin, "", func(ctx context.PluginContext) error {
+				attempts++
+				if c.backend {
+					//The backend call has a shorter deadline
+					backend, cancel := context.WithTimeout(ctx, time.Millisecond)
+					defer cancel()
+					ctx = backend
+				}
+				<-ctx.Done()
+				return ctx.Err()
+			})
+
+			timeout := time.Second
+			if !c.backend {
+				timeout = 20 * time.Millisecond
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			if err := exec(ctx); !errors.Is(err, sys_context.DeadlineExceeded) {
+				t.Fatalf("expect deadline exceeded error but got %v", err)
+			}
+			if attempts != c.attempts {
+				t.Fatalf("expect %d attempts but got %d", c.attempts, attempts)
+			}
+		})
+	}
+}
+
+func TestRetryAttemptValue(t *testing.T) {
+	bm := NewBaseManager().(*BaseManager)
+	bm.SetLogger(logger.Discard)
+
+	noJitter := 0.0
+	plugin := &spec.Plugin{
+		Name:    "billing",
+		Version: "1.0.0",
+		Retry:   &spec.Retry{MaxAttempts: 3, InitialBackoff: "1ms", Jitter: &noJitter},
+	}
+
+	attempts := []interface{}{}
+	exec := bm.retry(plugin, "", func(ctx context.PluginContext) error {
+		attempts = append(attempts, ctx.GetValue(context.AttemptKey))
+		return errors.New("failed")
+	})
+
+	//Not listed after the execution
+	ctx := context.Background()
+	if err := exec(ctx); err == nil {
+		t.Fatal("expect error of the last attempt")
+	}
+	if fmt.Sprint(attempts) != "[1 2 3]" {
+		t.Fatalf("expect attempts [1 2 3] but got %v", attempts)
+	}
+	if _, ok := context.Values(ctx)[context.AttemptKey]; ok {
+		t.Fatalf("expect no attempt value after the execution but got %v", context.Values(ctx))
+	}
+
+	//The value of the caller is restored, and the one of the parent is not shadowed
+	ctx.SetValue(context.AttemptKey, "caller")
+	derived, cancel := context.WithCancel(ctx)
+	defer cancel()
+	exec(derived)
+	if v := derived.GetValue(context.AttemptKey); v != "caller" {
+		t.Fatalf("expect attempt value of the parent but got %v", v)
+	}
+	exec(ctx)
+	if v := ctx.GetValue(context.AttemptKey); v != "caller" {
+		t.Fatalf("expect attempt value of the caller but got %v", v)
+	}
+}
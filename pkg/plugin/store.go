@@ -1,28 +1,90 @@
 package plugin
 
 import (
+	"sort"
 	"sync"
+	"time"
 
+	"github.com/Masterminds/semver"
 	"github.com/steven-zou/go-plugin/pkg/spec"
 )
 
-//Store defines how to maintain the loaded plugin
+//LatestVersion refers to the latest version of the plugin.
+//The highest stable version is the latest one, if no stable versions,
+//the highest pre-release version is used.
+const LatestVersion = "latest"
+
+//Store defines how to maintain the loaded plugin.
+//The plugin items are keyed by the plugin name and the semver version,
+//so multiple versions of the same plugin can be kept side by side.
 type Store interface {
 	//The total count of current items in the store
 	Size() uint
 
 	//Append the plugin item to the store
 	//If forced is set to be true, new plugin item will overwrite the existing one
+	//with the same name and version.
 	//Try best to append, ignore any errors
 	Put(item *spec.PluginItem, forced bool)
 
-	//Get the plugin item by name
+	//Replace the existing plugin item with the new one atomically,
+	//the new one can have a different version.
+	//If the existing one is not in the store, nothing is changed and false is returned.
+	Replace(existing *spec.PluginItem, item *spec.PluginItem) bool
+
+	//Get the latest version of the plugin item by name
 	//If existing, return the item and set the bool flag to true
 	Get(name string) (*spec.PluginItem, bool)
 
-	//Remove the plugin out of the store and return the removed plugin item
+	//Get the plugin item by name and version.
+	//If the version is empty or 'latest', the latest version is returned.
+	//If existing, return the item and set the bool flag to true
+	GetVersion(name string, version string) (*spec.PluginItem, bool)
+
+	//Get all the versions of the plugin items by name, sorted by the version ascending
+	Versions(name string) []*spec.PluginItem
+
+	//Get all the plugin items in the store
+	List() []*spec.PluginItem
+
+	//Remove the latest version of the plugin out of the store and return the removed plugin item
 	//If successfully removed, set the bool flag to true
 	Remove(name string) (*spec.PluginItem, bool)
+
+	//Remove the plugin with the version out of the store and return the removed plugin item
+	//If the version is empty or 'latest', the latest version is removed.
+	//If successfully removed, set the bool flag to true
+	RemoveVersion(name string, version string) (*spec.PluginItem, bool)
+
+	//Quarantine the plugin item with the reason. The quarantined item is kept
+	//in the store but should not be executed until it's released.
+	//If the item is not in the store, false is returned.
+	Quarantine(item *spec.PluginItem, reason string) bool
+
+	//Release the quarantined plugin item.
+	//If the item is not in the store or not quarantined, false is returned.
+	Release(item *spec.PluginItem) bool
+
+	//Get the quarantine of the plugin item, nil if it's not quarantined
+	Quarantined(item *spec.PluginItem) *Quarantine
+}
+
+//Quarantine is the state of the quarantined plugin item
+type Quarantine struct {
+	//Why the plugin item is quarantined
+	Reason string
+
+	//When the plugin item is quarantined
+	Time time.Time
+}
+
+//storeEntry is the plugin item kept in the store with the parsed version
+type storeEntry struct {
+	version *semver.Version
+	item    *spec.PluginItem
+
+	//Not nil if the item is quarantined
+	quarantine *Quarantine
 }
 
 //BaseStore is the default implementation of Store interface
@@ -30,24 +92,29 @@ type BaseStore struct {
 	//internal lock
 	lock *sync.RWMutex
 
-	//internal list
-	hash map[string]*spec.PluginItem
+	//internal list, the versions of each plugin are sorted ascending
+	hash map[string][]*storeEntry
 }
 
 //NewBaseStore is constructor of BaseStore
 func NewBaseStore() *BaseStore {
 	return &BaseStore{
 		lock: new(sync.RWMutex),
-		hash: make(map[string]*spec.PluginItem),
+		hash: make(map[string][]*storeEntry),
 	}
 }
 
 //Size is the implementation of same method in Store interface
 func (bs *BaseStore) Size() uint {
-	bs.lock.Lock()
-	defer bs.lock.Unlock()
+	bs.lock.RLock()
+	defer bs.lock.RUnlock()
 
-	return (uint)(len(bs.hash))
+	size := 0
+	for _, entries := range bs.hash {
+		size += len(entries)
+	}
+
+	return (uint)(size)
 }
 
 //Put is the implementation of same method in Store interface
@@ -61,35 +128,228 @@ func (bs *BaseStore) Put(item *spec.PluginItem, forced bool) {
 		return
 	}
 
-	_, ok := bs.hash[name]
-	if !ok || (ok && forced) {
-		bs.lock.Lock()
-		defer bs.lock.Unlock()
+	version, err := semver.NewVersion(item.Spec.Version)
+	if err != nil {
+		return
+	}
+
+	bs.lock.Lock()
+	defer bs.lock.Unlock()
+
+	bs.put(&storeEntry{version: version, item: item}, forced)
+}
+
+//Replace is the implementation of same method in Store interface
+func (bs *BaseStore) Replace(existing *spec.PluginItem, item *spec.PluginItem) bool {
+	if existing == nil || existing.Spec == nil || item == nil || item.Spec == nil || item.Executor == nil {
+		return false
+	}
+
+	if existing.Spec.Name != item.Spec.Name {
+		return false
+	}
+
+	version, err := semver.NewVersion(item.Spec.Version)
+	if err != nil {
+		return false
+	}
 
-		bs.hash[name] = item
+	bs.lock.Lock()
+	defer bs.lock.Unlock()
+
+	entries := bs.hash[existing.Spec.Name]
+	for i, entry := range entries {
+		if entry.item == existing {
+			bs.hash[existing.Spec.Name] = append(entries[:i:i], entries[i+1:]...)
+			bs.put(&storeEntry{version: version, item: item}, true)
+			return true
+		}
 	}
+
+	return false
+}
+
+//put the entry into the sorted entries, should be called with lock held
+func (bs *BaseStore) put(newEntry *storeEntry, forced bool) {
+	name := newEntry.item.Spec.Name
+
+	entries := bs.hash[name]
+	for _, entry := range entries {
+		if entry.version.Equal(newEntry.version) {
+			if forced {
+				entry.item, entry.quarantine = newEntry.item, nil
+			}
+			return
+		}
+	}
+
+	entries = append(entries, newEntry)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].version.LessThan(entries[j].version)
+	})
+	bs.hash[name] = entries
 }
 
 //Get is the implementation of same method in Store interface
 func (bs *BaseStore) Get(name string) (*spec.PluginItem, bool) {
+	return bs.GetVersion(name, LatestVersion)
+}
+
+//GetVersion is the implementation of same method in Store interface
+func (bs *BaseStore) GetVersion(name string, version string) (*spec.PluginItem, bool) {
+	bs.lock.RLock()
+	defer bs.lock.RUnlock()
+
+	entries := bs.hash[name]
+	if i := indexOfVersion(entries, version); i >= 0 {
+		return entries[i].item, true
+	}
+
+	return nil, false
+}
+
+//Versions is the implementation of same method in Store interface
+func (bs *BaseStore) Versions(name string) []*spec.PluginItem {
+	bs.lock.RLock()
+	defer bs.lock.RUnlock()
+
+	items := make([]*spec.PluginItem, 0, len(bs.hash[name]))
+	for _, entry := range bs.hash[name] {
+		items = append(items, entry.item)
+	}
+
+	return items
+}
+
+//List is the implementation of same method in Store interface
+func (bs *BaseStore) List() []*spec.PluginItem {
 	bs.lock.RLock()
 	defer bs.lock.RUnlock()
 
-	item, ok := bs.hash[name]
+	items := make([]*spec.PluginItem, 0, len(bs.hash))
+	for _, entries := range bs.hash {
+		for _, entry := range entries {
+			items = append(items, entry.item)
+		}
+	}
 
-	return item, ok
+	return items
 }
 
 //Remove is the implementation of same method in Store interface
 func (bs *BaseStore) Remove(name string) (*spec.PluginItem, bool) {
+	return bs.RemoveVersion(name, LatestVersion)
+}
+
+//RemoveVersion is the implementation of same method in Store interface
+func (bs *BaseStore) RemoveVersion(name string, version string) (*spec.PluginItem, bool) {
 	bs.lock.Lock()
 	defer bs.lock.Unlock()
 
-	item, ok := bs.hash[name]
-	if ok {
+	entries := bs.hash[name]
+	i := indexOfVersion(entries, version)
+	if i < 0 {
+		return nil, false
+	}
+
+	item := entries[i].item
+	entries = append(entries[:i:i], entries[i+1:]...)
+	if len(entries) == 0 {
 		delete(bs.hash, name)
-		return item, ok
+	} else {
+		bs.hash[name] = entries
 	}
 
-	return nil, false
+	return item, true
+}
+
+//Quarantine is the implementation of same method in Store interface
+func (bs *BaseStore) Quarantine(item *spec.PluginItem, reason string) bool {
+	bs.lock.Lock()
+	defer bs.lock.Unlock()
+
+	entry := bs.entryOf(item)
+	if entry == nil {
+		return false
+	}
+
+	entry.quarantine = &Quarantine{Reason: reason, Time: time.Now()}
+
+	return true
+}
+
+//Release is the implementation of same method in Store interface
+func (bs *BaseStore) Release(item *spec.PluginItem) bool {
+	bs.lock.Lock()
+	defer bs.lock.Unlock()
+
+	entry := bs.entryOf(item)
+	if entry == nil || entry.quarantine == nil {
+		return false
+	}
+
+	entry.quarantine = nil
+
+	return true
+}
+
+//Quarantined is the implementation of same method in Store interface
+func (bs *BaseStore) Quarantined(item *spec.PluginItem) *Quarantine {
+	bs.lock.RLock()
+	defer bs.lock.RUnlock()
+
+	if entry := bs.entryOf(item); entry != nil {
+		return entry.quarantine
+	}
+
+	return nil
+}
+
+//entryOf returns the entry of the plugin item, should be called with lock held.
+//If not found, nil is returned.
+func (bs *BaseStore) entryOf(item *spec.PluginItem) *storeEntry {
+	if item == nil || item.Spec == nil {
+		return nil
+	}
+
+	for _, entry := range bs.hash[item.Spec.Name] {
+		if entry.item == item {
+			return entry
+		}
+	}
+
+	return nil
+}
+
+//indexOfVersion returns the index of the entry with the version in the sorted entries.
+//If the version is empty or 'latest', the index of the latest one is returned.
+//If not found, -1 is returned.
+func indexOfVersion(entries []*storeEntry, version string) int {
+	if len(entries) == 0 {
+		return -1
+	}
+
+	if len(version) == 0 || version == LatestVersion {
+		for i := len(entries) - 1; i >= 0; i-- {
+			if len(entries[i].version.Prerelease()) == 0 {
+				return i
+			}
+		}
+
+		//No stable versions
+		return len(entries) - 1
+	}
+
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return -1
+	}
+
+	for i, entry := range entries {
+		if entry.version.Equal(v) {
+			return i
+		}
+	}
+
+	return -1
 }
@@ -0,0 +1,76 @@
+package plugin
+
+import (
+	"fmt"
+	"reflect"
+)
+
+//SymbolNotFoundError is returned when the symbol is not exported by the plugin
+type SymbolNotFoundError struct {
+	//Name of the plugin
+	Plugin string
+
+	//Name of the symbol
+	Symbol string
+
+	//The underlying error
+	Err error
+}
+
+//Error implements the error interface
+func (snf *SymbolNotFoundError) Error() string {
+	return fmt.Sprintf("symbol '%s' is not found in plugin '%s': %s", snf.Symbol, snf.Plugin, snf.Err)
+}
+
+//SymbolTypeError is returned when the symbol exported by the plugin has an unexpected type
+type SymbolTypeError struct {
+	//Name of the plugin
+	Plugin string
+
+	//Name of the symbol
+	Symbol string
+
+	//The expected type
+	Expected string
+
+	//The actual type of the symbol
+	Actual string
+}
+
+//Error implements the error interface
+func (ste *SymbolTypeError) Error() string {
+	return fmt.Sprintf("symbol '%s' in plugin '%s' has type %s but %s expected", ste.Symbol, ste.Plugin, ste.Actual, ste.Expected)
+}
+
+//LookupSymbol looks up the exported symbol of the plugin loaded by the manager
+//and asserts it to the type T.
+//The exported variables are looked up as pointers, they can be got with
+//either the pointer type '*V' or the value type 'V' (or an interface implemented by 'V').
+//If the symbol is not existing, a *SymbolNotFoundError will be returned.
+//If the symbol is not with type T, a *SymbolTypeError will be returned.
+func LookupSymbol[T any](mgr Manager, pluginName string, symbol string) (T, error) {
+	var zero T
+
+	sym, err := mgr.Lookup(pluginName, symbol)
+	if err != nil {
+		return zero, err
+	}
+
+	if v, ok := sym.(T); ok {
+		return v, nil
+	}
+
+	//Exported variable got with the value type
+	if rv := reflect.ValueOf(sym); rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		if v, ok := rv.Elem().Interface().(T); ok {
+			return v, nil
+		}
+	}
+
+	return zero, &SymbolTypeError{
+		Plugin:   pluginName,
+		Symbol:   symbol,
+		Expected: reflect.TypeOf((*T)(nil)).Elem().String(),
+		Actual:   fmt.Sprintf("%T", sym),
+	}
+}
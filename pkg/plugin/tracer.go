@@ -0,0 +1,301 @@
+package plugin
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/steven-zou/go-plugin/pkg/context"
+	"github.com/steven-zou/go-plugin/pkg/logger"
+	"github.com/steven-zou/go-plugin/pkg/spec"
+)
+
+//The names of the spans traced by the manager
+const (
+	//SpanValidate traces validating the plugin
+	SpanValidate = "plugin.validate"
+
+	//SpanOpen traces opening the plugin, e.g: plugin.Open for the so file
+	SpanOpen = "plugin.open"
+
+	//SpanInit traces calling the 'Init' function of the plugin
+	SpanInit = "plugin.init"
+
+	//SpanExecute traces executing the plugin entry
+	SpanExecute = "plugin.execute"
+)
+
+//SpanContext identifies the span in the trace
+type SpanContext struct {
+	//The ID of the trace, 16 bytes hex encoded
+	TraceID string
+
+	//The ID of the span, 8 bytes hex encoded
+	SpanID string
+}
+
+//IsValid checks if the span context has both IDs
+func (sc SpanContext) IsValid() bool {
+	return len(sc.TraceID) > 0 && len(sc.SpanID) > 0
+}
+
+//Span is the traced operation started by the Tracer
+type Span interface {
+	//Get the span context of the span
+	Context() SpanContext
+
+	//Set the attribute of the span
+	SetAttribute(key string, value string)
+
+	//End the span with the error of the operation, nil if succeeded
+	End(err error)
+}
+
+//Tracer is called by the manager around validating, opening, initializing
+//and executing the plugins. It can be implemented with any tracing system.
+type Tracer interface {
+	//Start the span with the name as a child of the parent span.
+	//If the parent is not valid, the span starts a new trace.
+	Start(name string, parent SpanContext) Span
+}
+
+//noopTracer is the default tracer which traces nothing
+type noopTracer struct{}
+
+type noopSpan struct{}
+
+//Start implements the Tracer interface
+func (noopTracer) Start(name string, parent SpanContext) Span {
+	return noopSpan{}
+}
+
+func (noopSpan) Context() SpanContext                  { return SpanContext{} }
+func (noopSpan) SetAttribute(key string, value string) {}
+func (noopSpan) End(err error)                         {}
+
+//JSONLinesTracer is the built-in tracer exporting the ended spans as JSON lines
+type JSONLinesTracer struct {
+	//internal lock
+	lock *sync.Mutex
+
+	//The JSON encoder of the output
+	encoder *json.Encoder
+
+	//Log the export errors
+	logger logger.Logger
+}
+
+//NewJSONLinesTracer is constructor of JSONLinesTracer
+func NewJSONLinesTracer(w io.Writer) *JSONLinesTracer {
+	return &JSONLinesTracer{
+		lock:    new(sync.Mutex),
+		encoder: json.NewEncoder(w),
+		logger:  logger.Default(),
+	}
+}
+
+//SetLogger sets the logger of the export errors
+func (jt *JSONLinesTracer) SetLogger(l logger.Logger) {
+	if l != nil {
+		jt.logger = l
+	}
+}
+
+//Start implements the Tracer interface
+func (jt *JSONLinesTracer) Start(name string, parent SpanContext) Span {
+	span := &jsonSpan{
+		tracer: jt,
+		lock:   new(sync.Mutex),
+		record: &spanRecord{
+			Name:       name,
+			SpanID:     newID(8),
+			Start:      time.Now(),
+			Attributes: make(map[string]string),
+		},
+	}
+
+	if parent.IsValid() {
+		span.record.TraceID, span.record.ParentSpanID = parent.TraceID, parent.SpanID
+	} else {
+		span.record.TraceID = newID(16)
+	}
+
+	return span
+}
+
+//export writes the span record as a JSON line
+func (jt *JSONLinesTracer) export(record *spanRecord) {
+	jt.lock.Lock()
+	defer jt.lock.Unlock()
+
+	if err := jt.encoder.Encode(record); err != nil {
+		jt.logger.Error("Export span error", "span", record.Name, "error", err)
+	}
+}
+
+//spanRecord is the JSON line of the span exported by JSONLinesTracer
+type spanRecord struct {
+	TraceID      string            `json:"trace_id"`
+	SpanID       string            `json:"span_id"`
+	ParentSpanID string            `json:"parent_span_id,omitempty"`
+	Name         string            `json:"name"`
+	Start        time.Time         `json:"start"`
+	End          time.Time         `json:"end"`
+	DurationMS   float64           `json:"duration_ms"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+	Error        string            `json:"error,omitempty"`
+}
+
+//jsonSpan is the span started by JSONLinesTracer
+type jsonSpan struct {
+	//The tracer exporting the span
+	tracer *JSONLinesTracer
+
+	//internal lock
+	lock *sync.Mutex
+
+	//The record of the span
+	record *spanRecord
+
+	//Whether the span is ended
+	ended bool
+}
+
+//Context implements the Span interface
+func (js *jsonSpan) Context() SpanContext {
+	return SpanContext{TraceID: js.record.TraceID, SpanID: js.record.SpanID}
+}
+
+//SetAttribute implements the Span interface
+func (js *jsonSpan) SetAttribute(key string, value string) {
+	js.lock.Lock()
+	defer js.lock.Unlock()
+
+	if !js.ended {
+		js.record.Attributes[key] = value
+	}
+}
+
+//End implements the Span interface, only the first call takes effect
+func (js *jsonSpan) End(err error) {
+	js.lock.Lock()
+	if js.ended {
+		js.lock.Unlock()
+		return
+	}
+	js.ended = true
+	js.record.End = time.Now()
+	js.record.DurationMS = float64(js.record.End.Sub(js.record.Start)) / float64(time.Millisecond)
+	if err != nil {
+		js.record.Error = err.Error()
+	}
+	js.lock.Unlock()
+
+	js.tracer.export(js.record)
+}
+
+//newID generates the random hex encoded ID with the byte size
+func newID(size int) string {
+	id := make([]byte, size)
+	rand.Read(id)
+
+	return hex.EncodeToString(id)
+}
+
+//SetTracer implements the interface method
+func (bm *BaseManager) SetTracer(tracer Tracer) {
+	if tracer == nil {
+		tracer = noopTracer{}
+	}
+
+	bm.lock.Lock()
+	bm.tracer = tracer
+	bm.lock.Unlock()
+}
+
+//activeTracer returns the tracer, it can be changed while the plugins are executing
+func (bm *BaseManager) activeTracer() Tracer {
+	bm.lock.Lock()
+	defer bm.lock.Unlock()
+
+	return bm.tracer
+}
+
+//startSpan starts the span of the plugin with the common attributes
+func (bm *BaseManager) startSpan(name string, parent SpanContext, plugin *spec.Plugin) Span {
+	span := bm.activeTracer().Start(name, parent)
+	span.SetAttribute("plugin.name", plugin.Name)
+	span.SetAttribute("plugin.version", plugin.Version)
+	if plugin.Source != nil {
+		span.SetAttribute("plugin.mode", plugin.Source.Mode)
+	}
+
+	return span
+}
+
+//trace wraps the executor of the plugin entry to trace the executions.
+//The trace ID and the span ID in the plugin context are used as the parent
+//span, and replaced with the IDs of the execution span while executing, so
+//the plugin can join its own spans to the trace.
+func (bm *BaseManager) trace(plugin *spec.Plugin, label string, exec spec.PluginExecutor) spec.PluginExecutor {
+	return func(ctx context.PluginContext) (err error) {
+		parent := SpanContext{
+			TraceID: stringValue(ctx, context.TraceIDKey),
+			SpanID:  stringValue(ctx, context.SpanIDKey),
+		}
+
+		span := bm.startSpan(SpanExecute, parent, plugin)
+		if len(label) > 0 {
+			span.SetAttribute("plugin.entry", label)
+		}
+		if id := stringValue(ctx, context.ExecutionIDKey); len(id) > 0 {
+			span.SetAttribute("execution.id", id)
+		}
+
+		sc := span.Context()
+		callerTraceID, callerSpanID := ctx.GetValue(context.TraceIDKey), ctx.GetValue(context.SpanIDKey)
+		if sc.IsValid() {
+			ctx.SetValue(context.TraceIDKey, sc.TraceID)
+			ctx.SetValue(context.SpanIDKey, sc.SpanID)
+		}
+
+		panicked := true
+		defer func() {
+			if panicked {
+				err = errPanicked
+			}
+			span.End(err)
+
+			if sc.IsValid() {
+				//Restore the span of the caller
+				restoreValue(ctx, context.TraceIDKey, callerTraceID)
+				restoreValue(ctx, context.SpanIDKey, callerSpanID)
+			}
+		}()
+
+		err = exec(ctx)
+		panicked = false
+
+		return err
+	}
+}
+
+//stringValue gets the string value from the plugin context, empty if it's not a string
+func stringValue(ctx context.ValueContext, key string) string {
+	s, _ := ctx.GetValue(key).(string)
+	return s
+}
+
+//restoreValue sets the value of the key back to the one got before changing it,
+//the key is deleted if it had no value
+func restoreValue(ctx context.ValueContext, key string, value interface{}) {
+	if value == nil {
+		context.DeleteValue(ctx, key)
+		return
+	}
+
+	ctx.SetValue(key, value)
+}
@@ -0,0 +1,304 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/steven-zou/go-plugin/pkg"
+	"github.com/steven-zou/go-plugin/pkg/context"
+	"github.com/steven-zou/go-plugin/pkg/spec"
+)
+
+//DefaultUpgradeGracePeriod is the default period to watch the health of the upgraded plugin
+const DefaultUpgradeGracePeriod = 10 * time.Second
+
+//The max interval of checking the health of the upgraded plugin in the grace period
+const upgradeHealthInterval = time.Second
+
+//RollbackError is returned when the upgraded plugin fails the health check
+//in the grace period and the previous version is restored
+type RollbackError struct {
+	//Name of the plugin
+	Plugin string
+
+	//The version restored
+	FromVersion string
+
+	//The version rolled back
+	ToVersion string
+
+	//The health check error
+	Err error
+}
+
+//Error implements the error interface
+func (re *RollbackError) Error() string {
+	return fmt.Sprintf("upgrade plugin %s from %s to %s is rolled back: %s", re.Plugin, re.FromVersion, re.ToVersion, re.Err)
+}
+
+//inFlight counts the running executions of a plugin item
+type inFlight struct {
+	//internal lock
+	lock *sync.Mutex
+
+	//The count of the running executions
+	running int
+
+	//Closed when no executions are running
+	idle chan struct{}
+}
+
+func newInFlight() *inFlight {
+	idle := make(chan struct{})
+	close(idle)
+
+	return &inFlight{
+		lock: new(sync.Mutex),
+		idle: idle,
+	}
+}
+
+//wrap the executor to count its executions
+func (f *inFlight) wrap(exec spec.PluginExecutor) spec.PluginExecutor {
+	return func(ctx context.PluginContext) error {
+		f.lock.Lock()
+		if f.running == 0 {
+			f.idle = make(chan struct{})
+		}
+		f.running++
+		f.lock.Unlock()
+
+		defer func() {
+			f.lock.Lock()
+			f.running--
+			if f.running == 0 {
+				close(f.idle)
+			}
+			f.lock.Unlock()
+		}()
+
+		return exec(ctx)
+	}
+}
+
+//wait until no executions are running or timeout.
+//If timeout, false is returned.
+func (f *inFlight) wait(timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		f.lock.Lock()
+		running, idle := f.running, f.idle
+		f.lock.Unlock()
+
+		if running == 0 {
+			return true
+		}
+
+		select {
+		case <-idle:
+		case <-timer.C:
+			return false
+		}
+	}
+}
+
+//UpgradePlugin implements the interface method
+func (bm *BaseManager) UpgradePlugin(name string) error {
+	if len(name) == 0 {
+		return errors.New("plugin name cannot be empty")
+	}
+
+	existing, ok := bm.store.Get(name)
+	if !ok {
+		return fmt.Errorf("plugin %s is not loaded", name)
+	}
+
+	if !bm.beginUpgrade(name) {
+		return fmt.Errorf("plugin %s is being upgraded", name)
+	}
+	defer bm.endUpgrade(name)
+
+	pluginSpec, err := bm.findUpgrade(existing)
+	if err != nil {
+		return err
+	}
+
+	return bm.upgrade(existing, pluginSpec)
+}
+
+//upgrade replaces the existing plugin item with the validated plugin,
+//the caller should mark the plugin as upgrading
+func (bm *BaseManager) upgrade(existing *spec.PluginItem, pluginSpec *spec.Plugin) (err error) {
+	name := existing.Spec.Name
+
+	defer func() {
+		bm.emit(EventUpgraded, pluginSpec, err)
+	}()
+
+	if err := checkDependencies(bm.store, pluginSpec); err != nil {
+		return err
+	}
+
+	//The dependents of the current version should be satisfied by the new one
+	for _, d := range dependents(bm.store, existing) {
+		for _, dep := range d.Spec.Dependencies {
+			if dep.Name == name && !satisfies(pluginSpec, dep) {
+				return fmt.Errorf("upgrade plugin %s to %s breaks the dependency '%s' of plugin %s:%s", name, pluginSpec.Version, dependencyRef(dep), d.Spec.Name, d.Spec.Version)
+			}
+		}
+	}
+
+	//Load and init the new version next to the current one
+	pluginItem, err := bm.loadItem(pluginSpec)
+	if err != nil {
+		return err
+	}
+
+	if !bm.store.Replace(existing, pluginItem) {
+		bm.retire(pluginItem)
+		return fmt.Errorf("plugin %s:%s is unloaded during upgrading", name, existing.Spec.Version)
+	}
+	bm.logger.Info("Upgrade plugin [SWAPPED]", "plugin", name, "from", existing.Spec.Version, "to", pluginSpec.Version)
+
+	if err := bm.watchHealth(pluginItem); err != nil {
+		//Roll back to the previous version
+		bm.store.Replace(pluginItem, existing)
+		bm.logger.Warn("Upgrade plugin [ROLLED BACK]", "plugin", name, "from", pluginSpec.Version, "to", existing.Spec.Version, "error", err)
+		bm.retire(pluginItem)
+
+		return &RollbackError{
+			Plugin:      name,
+			FromVersion: existing.Spec.Version,
+			ToVersion:   pluginSpec.Version,
+			Err:         err,
+		}
+	}
+
+	bm.retire(existing)
+	bm.logger.Info("Upgrade plugin [SUCCESS]", "plugin", name, "from", existing.Spec.Version, "to", pluginSpec.Version)
+
+	return nil
+}
+
+//beginUpgrade marks the plugin as upgrading.
+//If it's being upgraded, false is returned.
+func (bm *BaseManager) beginUpgrade(name string) bool {
+	bm.lock.Lock()
+	defer bm.lock.Unlock()
+
+	if bm.upgrading[name] {
+		return false
+	}
+	bm.upgrading[name] = true
+
+	return true
+}
+
+//endUpgrade clears the upgrading mark of the plugin
+func (bm *BaseManager) endUpgrade(name string) {
+	bm.lock.Lock()
+	defer bm.lock.Unlock()
+
+	delete(bm.upgrading, name)
+}
+
+//findUpgrade finds the highest version of the plugin in the base dir,
+//the version should be higher than the existing one.
+func (bm *BaseManager) findUpgrade(existing *spec.PluginItem) (*spec.Plugin, error) {
+	name := existing.Spec.Name
+
+	baseDir := bm.pluginBaseDir()
+	paths, err := bm.loader.Scan(baseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		found        *spec.Plugin
+		foundVersion *semver.Version
+	)
+	for _, p := range paths {
+		if n, _ := pkg.SplitPluginDirName(filepath.Base(p)); n != name {
+			continue
+		}
+
+		pluginSpec, err := bm.validatePlugin(p)
+		if err != nil {
+			bm.logger.Error("Plugin upgrading error", "path", p, "error", err)
+			continue
+		}
+
+		v, err := semver.NewVersion(pluginSpec.Version)
+		if err != nil {
+			continue
+		}
+
+		if foundVersion == nil || v.GreaterThan(foundVersion) {
+			found, foundVersion = pluginSpec, v
+		}
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf("no valid plugin %s is found in plugin base dir %s", name, baseDir)
+	}
+
+	if v, err := semver.NewVersion(existing.Spec.Version); err == nil && !foundVersion.GreaterThan(v) {
+		return nil, fmt.Errorf("no newer version of plugin %s than %s is found, the highest one is %s", name, existing.Spec.Version, found.Version)
+	}
+
+	return found, nil
+}
+
+//watchHealth checks the health of the upgraded plugin in the grace period.
+//The health is checked at least once if the 'Health' function is existing.
+func (bm *BaseManager) watchHealth(pluginItem *spec.PluginItem) error {
+	if pluginItem.Health == nil {
+		return nil
+	}
+
+	deadline := time.Now().Add(bm.activeGracePeriod())
+	for {
+		if err := bm.callLifecycle(pluginItem, healthSymbol, pluginItem.Health); err != nil {
+			return err
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil
+		}
+		if remaining > upgradeHealthInterval {
+			remaining = upgradeHealthInterval
+		}
+		time.Sleep(remaining)
+	}
+}
+
+//retire drains the running executions of the plugin item which is not in
+//the store any more and calls its 'Shutdown' function
+func (bm *BaseManager) retire(pluginItem *spec.PluginItem) {
+	bm.drain(pluginItem)
+
+	if err := bm.callLifecycle(pluginItem, shutdownSymbol, pluginItem.Shutdown); err != nil {
+		bm.logger.Error("Shutdown retired plugin error", "plugin", pluginItem.Spec.Name, "version", pluginItem.Spec.Version, "error", err)
+	}
+}
+
+//drain drops the execution state of the plugin item which is not in the store
+//any more and waits for its running executions to complete within the lifecycle timeout
+func (bm *BaseManager) drain(pluginItem *spec.PluginItem) {
+	bm.lock.Lock()
+	state := bm.executions[pluginItem]
+	delete(bm.executions, pluginItem)
+	bm.lock.Unlock()
+
+	timeout := bm.activeLifecycleTimeout()
+	if state != nil && !state.running.wait(timeout) {
+		bm.logger.Error("Plugin still has running executions", "plugin", pluginItem.Spec.Name, "version", pluginItem.Spec.Version, "timeout", timeout)
+	}
+}
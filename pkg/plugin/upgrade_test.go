@@ -0,0 +1,245 @@
+package plugin
+
+import (
+	sys_context "context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/steven-zou/go-plugin/pkg/context"
+	"github.com/steven-zou/go-plugin/pkg/logger"
+)
+
+//writePlugin writes the 'name@version' dir of the interpreted plugin with the go source
+//into the base dir. The extra fields are appended to the 'plugin.json', e.g: `, "timeout": "1s"`.
+func writePlugin(t *testing.T, baseDir string, name string, version string, extra string, source string) string {
+	dir := filepath.Join(baseDir, name+"@"+version)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := fmt.Sprintf(`{"name": %q, "version": %q, "source": {"mode": "interpreted", "path": "."}%s}`, name, version, extra)
+	if err := ioutil.WriteFile(filepath.Join(dir, "plugin.json"), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+//loadPlugins loads the plugins in the base dir with a silent manager
+func loadPlugins(t *testing.T, baseDir string) *BaseManager {
+	bm := NewBaseManager().(*BaseManager)
+	bm.SetLogger(logger.Discard)
+	if err := bm.SetPluginBaseDir(baseDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := bm.LoadPlugins(); err != nil {
+		t.Fatal(err)
+	}
+
+	return bm
+}
+
+//pluginSource is the interpreted plugin source, its 'Execute' blocks until the context is done
+//if 'block' is set, its 'Health' fails with 'health', and its 'Shutdown' creates the 'shutdown' file
+func pluginSource(block bool, health string, shutdown string) string {
+	return fmt.Sprintf(`package main
+
+import (
+	"errors"
+	"io/ioutil"
+
+	"github.com/steven-zou/go-plugin/pkg/context"
+)
+
+func Execute(ctx context.PluginContext) error {
+	if %v {
+		<-ctx.Done()
+	}
+	return nil
+}
+
+func Health(ctx context.PluginContext) error {
+	if len(%q) > 0 {
+		return errors.New(%q)
+	}
+	return nil
+}
+
+func Shutdown(ctx context.PluginContext) error {
+	return ioutil.WriteFile(%q, nil, 0644)
+}
+`, block, health, health, shutdown)
+}
+
+//exists checks if the file is existing
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestUpgradeRollback(t *testing.T) {
+	baseDir, err := ioutil.TempDir("", "upgrade-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(baseDir)
+
+	shutdown1, shutdown2 := filepath.Join(baseDir, "shutdown-1"), filepath.Join(baseDir, "shutdown-2")
+	writePlugin(t, baseDir, "billing", "1.0.0", "", pluginSource(false, "", shutdown1))
+	bm := loadPlugins(t, baseDir)
+	bm.SetUpgradeGracePeriod(10 * time.Millisecond)
+
+	writePlugin(t, baseDir, "billing", "2.0.0", "", pluginSource(false, "unhealthy", shutdown2))
+	err = bm.UpgradePlugin("billing")
+
+	var re *RollbackError
+	if !errors.As(err, &re) {
+		t.Fatalf("expect *RollbackError but got %v", err)
+	}
+	if re.FromVersion != "1.0.0" || re.ToVersion != "2.0.0" || !strings.Contains(re.Err.Error(), "unhealthy") {
+		t.Fatalf("expect rollback from 1.0.0 to 2.0.0 by the health error but got %s", re)
+	}
+
+	//The previous version is restored and still executable
+	pluginSpec, exec, err := bm.GetPlugin("billing")
+	if err != nil || pluginSpec.Version != "1.0.0" {
+		t.Fatalf("expect version 1.0.0 restored but got %v, %v", pluginSpec, err)
+	}
+	if err := exec(context.Background()); err != nil {
+		t.Fatalf("expect restored version executable but got %s", err)
+	}
+	if _, _, err := bm.GetPluginVersion("billing", "2.0.0"); err == nil {
+		t.Fatal("expect the rolled back version removed")
+	}
+
+	//Only the rolled back version is shut down
+	if !exists(shutdown2) || exists(shutdown1) {
+		t.Fatalf("expect only 2.0.0 shut down but got 1.0.0: %v, 2.0.0: %v", exists(shutdown1), exists(shutdown2))
+	}
+}
+
+func TestUpgradeDrain(t *testing.T) {
+	baseDir, err := ioutil.TempDir("", "upgrade-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(baseDir)
+
+	shutdown1, shutdown2 := filepath.Join(baseDir, "shutdown-1"), filepath.Join(baseDir, "shutdown-2")
+	writePlugin(t, baseDir, "billing", "1.0.0", "", pluginSource(true, "", shutdown1))
+	bm := loadPlugins(t, baseDir)
+	bm.SetUpgradeGracePeriod(0)
+
+	//Keep an execution of 1.0.0 running
+	ctx, cancel := sys_context.WithCancel(sys_context.Background())
+	defer cancel()
+	executed := make(chan error, 1)
+	go func() {
+		_, err := bm.Execute(ctx, "billing", nil, nil)
+		executed <- err
+	}()
+	deadline := time.Now().Add(time.Second)
+	for {
+		stats, err := bm.GetConcurrency("billing", "1.0.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.InFlight == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expect the execution of 1.0.0 running")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	writePlugin(t, baseDir, "billing", "2.0.0", "", pluginSource(false, "", shutdown2))
+	upgraded := make(chan error, 1)
+	go func() {
+		upgraded <- bm.UpgradePlugin("billing")
+	}()
+
+	//The new version is served while the previous one is draining
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case err := <-upgraded:
+		t.Fatalf("expect upgrading to wait for the running execution but returned %v", err)
+	default:
+	}
+	if pluginSpec, _, err := bm.GetPlugin("billing"); err != nil || pluginSpec.Version != "2.0.0" {
+		t.Fatalf("expect 2.0.0 served while draining but got %v, %v", pluginSpec, err)
+	}
+	if exists(shutdown1) {
+		t.Fatal("expect 1.0.0 not shut down before its execution completes")
+	}
+
+	cancel()
+	<-executed
+	select {
+	case err := <-upgraded:
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expect upgrading to complete after the execution completes")
+	}
+	if !exists(shutdown1) || exists(shutdown2) {
+		t.Fatalf("expect only 1.0.0 shut down but got 1.0.0: %v, 2.0.0: %v", exists(shutdown1), exists(shutdown2))
+	}
+}
+
+func TestFindUpgrade(t *testing.T) {
+	cases := []struct {
+		name     string
+		existing string
+		versions []string
+		expected string
+	}{
+		{"higher", "1.0.0", []string{"1.0.0", "1.2.0", "2.0.0"}, "2.0.0"},
+		{"same", "2.0.0", []string{"1.0.0", "2.0.0"}, ""},
+		{"lower", "2.0.0", []string{"1.0.0"}, ""},
+		{"pre-release is lower", "2.0.0", []string{"2.0.0-rc.1"}, ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			baseDir, err := ioutil.TempDir("", "upgrade-")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(baseDir)
+
+			for _, v := range c.versions {
+				writePlugin(t, baseDir, "billing", v, "", pluginSource(false, "", filepath.Join(baseDir, "shutdown")))
+			}
+			bm := NewBaseManager().(*BaseManager)
+			bm.SetLogger(logger.Discard)
+			if err := bm.SetPluginBaseDir(baseDir); err != nil {
+				t.Fatal(err)
+			}
+
+			found, err := bm.findUpgrade(newTestItem("billing", c.existing))
+			if len(c.expected) == 0 {
+				if err == nil || !strings.Contains(err.Error(), "no newer version") {
+					t.Fatalf("expect no newer version error but got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if found.Version != c.expected {
+				t.Fatalf("expect version %s but got %s", c.expected, found.Version)
+			}
+		})
+	}
+}
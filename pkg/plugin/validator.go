@@ -7,9 +7,12 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/Masterminds/semver"
 	"github.com/steven-zou/go-plugin/pkg"
+	"github.com/steven-zou/go-plugin/pkg/logger"
 	"github.com/steven-zou/go-plugin/pkg/spec"
 )
 
@@ -56,14 +59,29 @@ func (jfv *JSONFileValidator) Validate(params ...interface{}) (interface{}, erro
 		return nil, err
 	}
 
-	//Plugin dir name should be equal with the name of the plugin
+	//Plugin dir name should be equal with the name of the plugin,
+	//or the name and the version with 'name@version' layout
 	fi, err := os.Stat(pluginDirPath)
 	if err != nil {
 		//Actually, should not come here
 		return nil, err
 	}
-	if fi.Name() != pluginSpec.Name {
-		return nil, fmt.Errorf("Name conflicts: expect %s but got %s in the metadata json file", fi.Name(), pluginSpec.Name)
+	name, version := pkg.SplitPluginDirName(fi.Name())
+	if name != pluginSpec.Name {
+		return nil, fmt.Errorf("Name conflicts: expect %s but got %s in the metadata json file", name, pluginSpec.Name)
+	}
+	if len(version) > 0 {
+		dirVersion, err := semver.NewVersion(version)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version in plugin dir name %s: %s", fi.Name(), err)
+		}
+		specVersion, err := semver.NewVersion(pluginSpec.Version)
+		if err != nil {
+			return nil, err
+		}
+		if !dirVersion.Equal(specVersion) {
+			return nil, fmt.Errorf("Version conflicts: expect %s but got %s in the metadata json file", version, pluginSpec.Version)
+		}
 	}
 
 	return pluginSpec, nil
@@ -97,8 +115,86 @@ func (sv *SpecValidator) Validate(params ...interface{}) (interface{}, error) {
 	}
 
 	if pluginSpec.Source.Mode != pkg.PluginSourceModeLocal &&
-		pluginSpec.Source.Mode != pkg.PluginSourceModeRemote {
-		return nil, fmt.Errorf("Only support mode [%s, %s]", pkg.PluginSourceModeLocal, pkg.PluginSourceModeRemote)
+		pluginSpec.Source.Mode != pkg.PluginSourceModeLocalSrc &&
+		pluginSpec.Source.Mode != pkg.PluginSourceModeRemote &&
+		pluginSpec.Source.Mode != pkg.PluginSourceModeProcess &&
+		pluginSpec.Source.Mode != pkg.PluginSourceModeInterpreted &&
+		pluginSpec.Source.Mode != pkg.PluginSourceModeWasm {
+		return nil, fmt.Errorf("Only support mode [%s, %s, %s, %s, %s, %s]", pkg.PluginSourceModeLocal, pkg.PluginSourceModeLocalSrc, pkg.PluginSourceModeRemote, pkg.PluginSourceModeProcess, pkg.PluginSourceModeInterpreted, pkg.PluginSourceModeWasm)
+	}
+
+	if len(pluginSpec.Timeout) > 0 {
+		if timeout, err := time.ParseDuration(pluginSpec.Timeout); err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid timeout '%s', should be a positive duration like '30s'", pluginSpec.Timeout)
+		}
+	}
+
+	if c := pluginSpec.Concurrency; c != nil {
+		if c.MaxInFlight <= 0 {
+			return nil, errors.New("concurrency.max_in_flight should be greater than 0")
+		}
+		if c.QueueLength < 0 {
+			return nil, errors.New("concurrency.queue_length can not be negative")
+		}
+		if len(c.QueueTimeout) > 0 {
+			if timeout, err := time.ParseDuration(c.QueueTimeout); err != nil || timeout <= 0 {
+				return nil, fmt.Errorf("invalid concurrency.queue_timeout '%s', should be a positive duration like '5s'", c.QueueTimeout)
+			}
+		}
+	}
+
+	if cb := pluginSpec.CircuitBreaker; cb != nil {
+		if cb.ErrorRate <= 0 || cb.ErrorRate > 1 {
+			return nil, errors.New("circuit_breaker.error_rate should be in (0, 1]")
+		}
+		if cb.MinRequests < 0 || cb.HalfOpenRequests < 0 {
+			return nil, errors.New("circuit_breaker.min_requests and circuit_breaker.half_open_requests can not be negative")
+		}
+		for field, value := range map[string]string{"window": cb.Window, "open_duration": cb.OpenDuration} {
+			if len(value) == 0 {
+				continue
+			}
+			if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid circuit_breaker.%s '%s', should be a positive duration like '30s'", field, value)
+			}
+		}
+	}
+
+	if r := pluginSpec.Retry; r != nil {
+		if r.MaxAttempts <= 0 {
+			return nil, errors.New("retry.max_attempts should be greater than 0")
+		}
+		for field, value := range map[string]string{"initial_backoff": r.InitialBackoff, "max_backoff": r.MaxBackoff} {
+			if len(value) == 0 {
+				continue
+			}
+			if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid retry.%s '%s', should be a positive duration like '100ms'", field, value)
+			}
+		}
+		if r.Multiplier != 0 && r.Multiplier < 1 {
+			return nil, errors.New("retry.multiplier should not be less than 1")
+		}
+		if r.Jitter != nil && (*r.Jitter < 0 || *r.Jitter > 1) {
+			return nil, errors.New("retry.jitter should be in [0, 1]")
+		}
+		for _, class := range r.RetryOn {
+			if class != RetryOnError && class != RetryOnTimeout && class != RetryOnOverloaded && class != RetryOnCrash {
+				return nil, fmt.Errorf("unknown retry.retry_on class '%s', should be one of [%s, %s, %s, %s]", class, RetryOnError, RetryOnTimeout, RetryOnOverloaded, RetryOnCrash)
+			}
+		}
+	}
+
+	for _, dep := range pluginSpec.Dependencies {
+		if dep == nil || len(dep.Name) == 0 {
+			return nil, errors.New("missing dependency name")
+		}
+		if dep.Name == pluginSpec.Name {
+			return nil, fmt.Errorf("plugin %s can not depend on itself", pluginSpec.Name)
+		}
+		if _, err := parseConstraint(dep.Version); err != nil {
+			return nil, fmt.Errorf("invalid version constraint of dependency '%s': %s", dep.Name, err)
+		}
 	}
 
 	return pluginSpec, nil
@@ -150,25 +246,226 @@ func (lsv *LocalSourceValidator) Validate(params ...interface{}) (interface{}, e
 	return pluginSpec, nil
 }
 
+//LocalSrcValidator validates the local source package of the 'local_src' and 'interpreted' modes
+type LocalSrcValidator struct{}
+
+//Validate is the implementation of Validator interface
+func (lsv *LocalSrcValidator) Validate(params ...interface{}) (interface{}, error) {
+	if len(params) < 2 {
+		return nil, errors.New("plugin json object and plugin base dir are required")
+	}
+
+	pluginSpec, ok := params[0].(*spec.Plugin)
+	if !ok {
+		return nil, errors.New("invalid plugin spec object")
+	}
+
+	if pluginSpec.Source == nil {
+		return nil, errors.New("plugin source missing")
+	}
+
+	//If the mode is not local source mode, just ignore it
+	if pluginSpec.Source.Mode != pkg.PluginSourceModeLocalSrc &&
+		pluginSpec.Source.Mode != pkg.PluginSourceModeInterpreted {
+		return pluginSpec, nil
+	}
+
+	pluginBaseDir := fmt.Sprintf("%s", params[1])
+	//The source package should be kept inside the plugin dir
+	if filepath.IsAbs(pluginSpec.Source.Path) {
+		return nil, fmt.Errorf("plugin source path %s should be relative to the plugin dir", pluginSpec.Source.Path)
+	}
+	pluginSrcDir := filepath.Join(pluginBaseDir, pluginSpec.Source.Path)
+	if rel, err := filepath.Rel(pluginBaseDir, pluginSrcDir); err != nil || strings.HasPrefix(rel, "..") {
+		return nil, fmt.Errorf("plugin source path %s is out of the plugin dir", pluginSpec.Source.Path)
+	}
+
+	if !pkg.IsDir(pluginSrcDir) {
+		return nil, fmt.Errorf("plugin source dir %s is not existing", pluginSrcDir)
+	}
+
+	goFiles, err := filepath.Glob(filepath.Join(pluginSrcDir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	if len(goFiles) == 0 {
+		return nil, fmt.Errorf("no go source files found under plugin source dir %s", pluginSrcDir)
+	}
+
+	//Override the source path to absolute path
+	pluginSpec.Source.Path = pluginSrcDir
+
+	return pluginSpec, nil
+}
+
+//ProcessSourceValidator validates the plugin executable of the process mode
+type ProcessSourceValidator struct{}
+
+//Validate is the implementation of Validator interface
+func (psv *ProcessSourceValidator) Validate(params ...interface{}) (interface{}, error) {
+	if len(params) < 2 {
+		return nil, errors.New("plugin json object and plugin base dir are required")
+	}
+
+	pluginSpec, ok := params[0].(*spec.Plugin)
+	if !ok {
+		return nil, errors.New("invalid plugin spec object")
+	}
+
+	if pluginSpec.Source == nil {
+		return nil, errors.New("plugin source missing")
+	}
+
+	//If the mode is not process mode, just ignore it
+	if pluginSpec.Source.Mode != pkg.PluginSourceModeProcess {
+		return pluginSpec, nil
+	}
+
+	pluginBaseDir := fmt.Sprintf("%s", params[1])
+	//plugin executable path
+	var pluginExecPath string
+	if filepath.IsAbs(pluginSpec.Source.Path) {
+		pluginExecPath = pluginSpec.Source.Path
+	} else {
+		pluginExecPath = filepath.Join(pluginBaseDir, pluginSpec.Source.Path)
+	}
+
+	fi, err := os.Stat(pluginExecPath)
+	if err != nil {
+		return nil, fmt.Errorf("plugin executable %s is not existing", pluginExecPath)
+	}
+
+	if !fi.Mode().IsRegular() || fi.Mode().Perm()&0111 == 0 {
+		return nil, fmt.Errorf("plugin executable %s is not an executable file", pluginExecPath)
+	}
+
+	//Override the executable path to absolute path
+	pluginSpec.Source.Path = pluginExecPath
+
+	return pluginSpec, nil
+}
+
+//WasmSourceValidator validates the wasm module of the wasm mode
+type WasmSourceValidator struct{}
+
+//Validate is the implementation of Validator interface
+func (wsv *WasmSourceValidator) Validate(params ...interface{}) (interface{}, error) {
+	if len(params) < 2 {
+		return nil, errors.New("plugin json object and plugin base dir are required")
+	}
+
+	pluginSpec, ok := params[0].(*spec.Plugin)
+	if !ok {
+		return nil, errors.New("invalid plugin spec object")
+	}
+
+	if pluginSpec.Source == nil {
+		return nil, errors.New("plugin source missing")
+	}
+
+	//If the mode is not wasm mode, just ignore it
+	if pluginSpec.Source.Mode != pkg.PluginSourceModeWasm {
+		return pluginSpec, nil
+	}
+
+	pluginBaseDir := fmt.Sprintf("%s", params[1])
+	//wasm module file path
+	var pluginWasmFilePath string
+	if filepath.IsAbs(pluginSpec.Source.Path) {
+		pluginWasmFilePath = pluginSpec.Source.Path
+	} else {
+		pluginWasmFilePath = filepath.Join(pluginBaseDir, pluginSpec.Source.Path)
+	}
+
+	if !pkg.FileExists(pluginWasmFilePath) {
+		return nil, fmt.Errorf("plugin wasm file %s is not existing", pluginWasmFilePath)
+	}
+
+	if filepath.Ext(pluginWasmFilePath) != ".wasm" {
+		return nil, fmt.Errorf("%s.wasm file is missing", pluginSpec.Name)
+	}
+
+	//Zero means the default limit
+	if pluginSpec.Wasm != nil && pluginSpec.Wasm.MemoryLimit > MaxWasmMemoryLimit {
+		return nil, fmt.Errorf("wasm.memory_limit %d should be in range [1, %d] (MiB)", pluginSpec.Wasm.MemoryLimit, MaxWasmMemoryLimit)
+	}
+
+	//Override the wasm file path to absolute path
+	pluginSpec.Source.Path = pluginWasmFilePath
+
+	return pluginSpec, nil
+}
+
 //RemoteSourceValidator validates the remote source
 type RemoteSourceValidator struct{}
 
 //Validate is the implementation of Validator interface
-//TODO:
 func (rsv *RemoteSourceValidator) Validate(params ...interface{}) (interface{}, error) {
-	return nil, nil
+	if len(params) == 0 {
+		return nil, errors.New("plugin json object is missing")
+	}
+
+	pluginSpec, ok := params[0].(*spec.Plugin)
+	if !ok {
+		return nil, errors.New("invalid plugin spec object")
+	}
+
+	if pluginSpec.Source == nil {
+		return nil, errors.New("plugin source missing")
+	}
+
+	//If the mode is not remote mode, just ignore it
+	if pluginSpec.Source.Mode != pkg.PluginSourceModeRemote {
+		return pluginSpec, nil
+	}
+
+	repo := pluginSpec.Source.Path
+	if len(repo) == 0 {
+		return nil, errors.New("git repository of the plugin source is missing")
+	}
+
+	//Both the URLs ('file://', 'https://', 'ssh://' and 'user@host:path')
+	//and the local repositories are acceptable
+	if !strings.Contains(repo, ":") {
+		if !filepath.IsAbs(repo) {
+			return nil, fmt.Errorf("local git repository path %s should be absolute", repo)
+		}
+
+		if !pkg.IsDir(repo) {
+			return nil, fmt.Errorf("local git repository %s is not existing", repo)
+		}
+	}
+
+	if strings.HasPrefix(repo, "-") {
+		return nil, fmt.Errorf("invalid git repository %s", repo)
+	}
+
+	if len(pluginSpec.Source.Ref) == 0 {
+		return nil, errors.New("git ref of the plugin source is missing")
+	}
+
+	//Not a valid ref name, and would be taken as an option of git
+	if strings.HasPrefix(pluginSpec.Source.Ref, "-") {
+		return nil, fmt.Errorf("invalid git ref %s", pluginSpec.Source.Ref)
+	}
+
+	return pluginSpec, nil
 }
 
 //BaseValidatorChain build a validation pipeline with 'JSONFileValidator' and 'SpecValidator'.
 type BaseValidatorChain struct {
 	//The validator list
 	validators []Validator
+
+	//Log the validation failures
+	logger logger.Logger
 }
 
 //NewBaseValidatorChain creates a validator chain
 func NewBaseValidatorChain(validators ...Validator) Validator {
 	bvc := &BaseValidatorChain{
 		validators: make([]Validator, 0),
+		logger:     logger.Default(),
 	}
 
 	if len(validators) > 0 {
@@ -178,6 +475,21 @@ func NewBaseValidatorChain(validators ...Validator) Validator {
 	return bvc
 }
 
+//SetLogger implements the LoggerSetter interface,
+//the logger is also set to the validators in the chain accepting it
+func (bvc *BaseValidatorChain) SetLogger(l logger.Logger) {
+	if l == nil {
+		return
+	}
+
+	bvc.logger = l
+	for _, vl := range bvc.validators {
+		if ls, ok := vl.(LoggerSetter); ok {
+			ls.SetLogger(l)
+		}
+	}
+}
+
 //Validate is the implementation of Validator interface
 func (bvc *BaseValidatorChain) Validate(params ...interface{}) (interface{}, error) {
 	if len(bvc.validators) == 0 {
@@ -202,6 +514,7 @@ func (bvc *BaseValidatorChain) Validate(params ...interface{}) (interface{}, err
 		}
 
 		if err != nil {
+			bvc.logger.Debug("Validation failed", "validator", fmt.Sprintf("%T", vl), "error", err)
 			return nil, err
 		}
 	}
@@ -0,0 +1,378 @@
+package plugin
+
+import (
+	sys_context "context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/steven-zou/go-plugin/pkg/context"
+	"github.com/steven-zou/go-plugin/pkg/logger"
+	"github.com/steven-zou/go-plugin/pkg/process"
+	"github.com/steven-zou/go-plugin/pkg/spec"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+const (
+	//DefaultWasmMemoryLimit is the default max memory of the wasm module instance in MiB
+	DefaultWasmMemoryLimit = 64
+
+	//MaxWasmMemoryLimit is the max memory (4GiB) in MiB which can be addressed by the wasm module
+	MaxWasmMemoryLimit = 4096
+)
+
+const (
+	//The size of the wasm memory page
+	wasmPageSize = 64 * 1024
+
+	//The max pages of the wasm memory
+	wasmMaxPages = 65536
+
+	//The exported function to allocate the guest memory for the input,
+	//the signature is 'alloc(size i32) i32'
+	wasmAllocFunc = "alloc"
+
+	//The optional exported function to release the guest memory allocated
+	//for the input and the output, the signature is 'free(ptr i32)'
+	wasmFreeFunc = "free"
+
+	//The start function of the reactor module, called once after instantiating
+	wasmStartFunc = "_initialize"
+)
+
+//ErrWasmClosed is returned when calling the closed wasm plugin
+var ErrWasmClosed = errors.New("wasm plugin is closed")
+
+//wasmInput is passed to the exported functions of the wasm module as JSON
+type wasmInput struct {
+	//The JSON encoded plugin context values
+	Values map[string]json.RawMessage `json:"values,omitempty"`
+
+	//The deadline of the plugin context
+	Deadline *time.Time `json:"deadline,omitempty"`
+}
+
+//wasmOutput is returned by the exported functions of the wasm module as JSON
+type wasmOutput struct {
+	//The JSON encoded values set to the plugin context by the plugin
+	Values map[string]json.RawMessage `json:"values,omitempty"`
+
+	//The error message returned by the plugin
+	Error string `json:"error,omitempty"`
+
+	//Whether the error is marked as non-retryable by the plugin
+	NonRetryable bool `json:"non_retryable,omitempty"`
+
+	//Whether the plugin panicked in the call, the error is the recovered value
+	Crashed bool `json:"crashed,omitempty"`
+}
+
+//wasmModule runs the wasm module of the plugin in the sandbox.
+//The module instance is not reentrant, the calls are serialized.
+//
+//The entries and the lifecycle functions are exported by the module with the
+//signature 'fn(ptr i32, len i32) i64'. The params locate the JSON encoded
+//wasmInput written to the memory allocated by 'alloc'. The result packs the
+//pointer (high 32 bits) and the length (low 32 bits) of the JSON encoded
+//wasmOutput, a zero length means no output.
+type wasmModule struct {
+	//Name of the plugin
+	name string
+
+	//Log the re-instantiating and the closing errors
+	logger logger.Logger
+
+	//The runtime hosting the module
+	runtime wazero.Runtime
+
+	//The compiled module
+	compiled wazero.CompiledModule
+
+	//internal lock
+	lock *sync.Mutex
+
+	//The running module instance
+	instance api.Module
+
+	//Whether the module is closed
+	closed bool
+}
+
+//instantiate the compiled module, should be called with lock held
+func (wm *wasmModule) instantiate(ctx sys_context.Context) error {
+	config := wazero.NewModuleConfig().
+		WithName("").
+		WithStartFunctions(wasmStartFunc).
+		WithStdout(os.Stderr).
+		WithStderr(os.Stderr).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithSysNanosleep().
+		WithRandSource(rand.Reader)
+
+	instance, err := wm.runtime.InstantiateModule(ctx, wm.compiled, config)
+	if err != nil {
+		return fmt.Errorf("failed to instantiate wasm plugin %s: %s", wm.name, err)
+	}
+
+	if instance.Memory() == nil || instance.ExportedFunction(wasmAllocFunc) == nil {
+		instance.Close(ctx)
+		return fmt.Errorf("wasm plugin %s should export the 'memory' and the '%s' function", wm.name, wasmAllocFunc)
+	}
+
+	wm.instance = instance
+
+	return nil
+}
+
+//call the exported function of the module with the plugin context.
+//The module instance closed by the deadline or the trap is re-instantiated
+//and its 'Init' function is called again.
+func (wm *wasmModule) call(ctx context.PluginContext, fn string) error {
+	wm.lock.Lock()
+	defer wm.lock.Unlock()
+
+	if wm.closed {
+		return ErrWasmClosed
+	}
+
+	if wm.instance.IsClosed() {
+		if err := wm.instantiate(ctx); err != nil {
+			return err
+		}
+
+		if _, ok := wm.compiled.ExportedFunctions()[initSymbol]; ok {
+			initCtx, cancel := context.WithTimeout(context.Background(), DefaultLifecycleTimeout)
+			err := wm.invoke(initCtx, initSymbol)
+			cancel()
+			if err != nil {
+				return fmt.Errorf("failed to init re-instantiated wasm plugin %s: %s", wm.name, err)
+			}
+		}
+
+		wm.logger.Info("Wasm plugin re-instantiated", "plugin", wm.name)
+	}
+
+	return wm.invoke(ctx, fn)
+}
+
+//invoke the exported function of the running instance, should be called with lock held
+func (wm *wasmModule) invoke(ctx context.PluginContext, fn string) error {
+	f := wm.instance.ExportedFunction(fn)
+	if f == nil {
+		return fmt.Errorf("function '%s' is not exported by wasm plugin %s", fn, wm.name)
+	}
+
+	input := &wasmInput{
+		Values: process.EncodeValues(context.Values(ctx)),
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		input.Deadline = &deadline
+	}
+	data, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+
+	inPtr, err := wm.write(ctx, data)
+	if err != nil {
+		return wm.fail(ctx, err)
+	}
+
+	results, err := f.Call(ctx, uint64(inPtr), uint64(len(data)))
+	if err != nil {
+		return wm.fail(ctx, err)
+	}
+	wm.free(ctx, inPtr)
+
+	outPtr, outLen := uint32(results[0]>>32), uint32(results[0])
+	if outLen == 0 {
+		return nil
+	}
+
+	data, ok := wm.instance.Memory().Read(outPtr, outLen)
+	if !ok {
+		return fmt.Errorf("output of wasm plugin %s is out of memory range", wm.name)
+	}
+
+	output := &wasmOutput{}
+	err = json.Unmarshal(data, output)
+	wm.free(ctx, outPtr)
+	if err != nil {
+		return fmt.Errorf("malformed output of wasm plugin %s: %s", wm.name, err)
+	}
+
+	values, err := process.DecodeValues(output.Values)
+	if err != nil {
+		return err
+	}
+	for k, v := range values {
+		ctx.SetValue(k, v)
+	}
+
+	if len(output.Error) > 0 {
+		if output.Crashed {
+			return &CrashedError{Plugin: wm.name, Err: errors.New(output.Error)}
+		}
+		if output.NonRetryable {
+			return context.NonRetryable(errors.New(output.Error))
+		}
+		return errors.New(output.Error)
+	}
+
+	return nil
+}
+
+//write the data to the guest memory allocated by the 'alloc' function
+func (wm *wasmModule) write(ctx sys_context.Context, data []byte) (uint32, error) {
+	results, err := wm.instance.ExportedFunction(wasmAllocFunc).Call(ctx, uint64(len(data)))
+	if err != nil {
+		return 0, err
+	}
+
+	ptr := uint32(results[0])
+	if !wm.instance.Memory().Write(ptr, data) {
+		return 0, fmt.Errorf("memory allocated by wasm plugin %s is out of range", wm.name)
+	}
+
+	return ptr, nil
+}
+
+//free the guest memory if the 'free' function is exported
+func (wm *wasmModule) free(ctx sys_context.Context, ptr uint32) {
+	if f := wm.instance.ExportedFunction(wasmFreeFunc); f != nil {
+		f.Call(ctx, uint64(ptr))
+	}
+}
+
+//fail handles the failed call. The instance is closed by the runtime if the context
+//is done, otherwise it's trapped and closed here as its state is not reliable.
+func (wm *wasmModule) fail(ctx sys_context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+
+	wm.instance.Close(sys_context.Background())
+
+	return &CrashedError{Plugin: wm.name, Err: fmt.Errorf("trapped: %s", err)}
+}
+
+//lookup returns the executor calling the exported function with the expected signature
+func (wm *wasmModule) lookup(symbol string) (interface{}, error) {
+	def, ok := wm.compiled.ExportedFunctions()[symbol]
+	if !ok {
+		return nil, &SymbolNotFoundError{Plugin: wm.name, Symbol: symbol, Err: errors.New("function is not exported by the wasm module")}
+	}
+
+	params, results := def.ParamTypes(), def.ResultTypes()
+	if len(params) != 2 || params[0] != api.ValueTypeI32 || params[1] != api.ValueTypeI32 ||
+		len(results) != 1 || results[0] != api.ValueTypeI64 {
+		return nil, fmt.Errorf("function %s in wasm plugin %s should have signature 'fn(i32, i32) i64'", symbol, wm.name)
+	}
+
+	return func(ctx context.PluginContext) error {
+		return wm.call(ctx, symbol)
+	}, nil
+}
+
+//close the module and the runtime
+func (wm *wasmModule) close(ctx sys_context.Context) {
+	wm.lock.Lock()
+	defer wm.lock.Unlock()
+
+	if wm.closed {
+		return
+	}
+	wm.closed = true
+
+	if err := wm.runtime.Close(ctx); err != nil {
+		wm.logger.Error("Close wasm plugin error", "plugin", wm.name, "error", err)
+	}
+}
+
+//loadWasm compiles and instantiates the wasm module with the memory limit and builds the plugin item
+func loadWasm(plugin *spec.Plugin, l logger.Logger) (*spec.PluginItem, error) {
+	wasmFile := plugin.Source.Path
+
+	binary, err := os.ReadFile(wasmFile)
+	if err != nil {
+		return nil, err
+	}
+
+	memoryLimit := uint32(DefaultWasmMemoryLimit)
+	if plugin.Wasm != nil && plugin.Wasm.MemoryLimit > 0 {
+		memoryLimit = plugin.Wasm.MemoryLimit
+	}
+	pages := uint64(memoryLimit) * (1024 * 1024 / wasmPageSize)
+	if pages > wasmMaxPages {
+		return nil, fmt.Errorf("memory limit %dMiB of wasm plugin %s exceeds %dMiB", memoryLimit, plugin.Name, MaxWasmMemoryLimit)
+	}
+
+	ctx := sys_context.Background()
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(uint32(pages)).
+		WithCloseOnContextDone(true))
+
+	wm := &wasmModule{
+		name:    plugin.Name,
+		logger:  l,
+		runtime: runtime,
+		lock:    new(sync.Mutex),
+	}
+
+	//The WASI without any mounted files, env or args
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+		wm.close(ctx)
+		return nil, err
+	}
+
+	if wm.compiled, err = runtime.CompileModule(ctx, binary); err != nil {
+		wm.close(ctx)
+		return nil, fmt.Errorf("failed to compile wasm plugin '%s': %s", wasmFile, err)
+	}
+
+	wm.lock.Lock()
+	err = wm.instantiate(ctx)
+	wm.lock.Unlock()
+	if err != nil {
+		wm.close(ctx)
+		return nil, err
+	}
+
+	item, err := newPluginItem(plugin, wm.lookup, wasmFile)
+	if err != nil {
+		wm.close(ctx)
+		return nil, err
+	}
+
+	if initFn := item.Init; initFn != nil {
+		item.Init = func(ctx context.PluginContext) error {
+			err := initFn(ctx)
+			if err != nil {
+				//The plugin will not be registered
+				wm.close(ctx)
+			}
+
+			return err
+		}
+	}
+
+	shutdownFn := item.Shutdown
+	item.Shutdown = func(ctx context.PluginContext) error {
+		var err error
+		if shutdownFn != nil {
+			err = shutdownFn(ctx)
+		}
+		wm.close(ctx)
+
+		return err
+	}
+
+	return item, nil
+}
@@ -0,0 +1,347 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/steven-zou/go-plugin/pkg"
+	"github.com/steven-zou/go-plugin/pkg/spec"
+)
+
+const (
+	//DefaultWatchInterval is the default interval of polling the plugin base dir
+	DefaultWatchInterval = 2 * time.Second
+
+	//DefaultWatchDebounce is the default period a change should be stable for before applied
+	DefaultWatchDebounce = 5 * time.Second
+
+	//The buffer size of the watch event subscription
+	watchEventBuffer = 64
+)
+
+//The actions taken by the watcher
+const (
+	//WatchActionLoad loads the new plugin dir or bundle
+	WatchActionLoad = "load"
+
+	//WatchActionUpgrade reloads the changed plugin dir or bundle with a newer version
+	WatchActionUpgrade = "upgrade"
+
+	//WatchActionReload reloads the changed plugin dir or bundle with the same or a lower version
+	WatchActionReload = "reload"
+
+	//WatchActionUnload unloads the plugin whose dir or bundle is removed
+	WatchActionUnload = "unload"
+)
+
+//WatchEvent reports the action taken by the watcher
+type WatchEvent struct {
+	//The action taken
+	Action string
+
+	//The path of the plugin dir or bundle
+	Path string
+
+	//Name of the plugin
+	Name string
+
+	//Version of the plugin, empty if the plugin is not valid
+	Version string
+
+	//When the action is taken
+	Time time.Time
+
+	//The error of the action, nil if succeeded
+	Err error
+}
+
+//watchState is the state of the watched plugin dir or bundle
+type watchState struct {
+	//The latest fingerprint
+	fingerprint string
+
+	//The fingerprint applied
+	applied string
+
+	//When the latest change is seen
+	changedAt time.Time
+
+	//Whether it's removed
+	removed bool
+}
+
+//watcher polls the plugin base dir and applies the stable changes
+type watcher struct {
+	//The manager applying the changes
+	manager *BaseManager
+
+	//The polling interval
+	interval time.Duration
+
+	//The period the change should be stable for
+	debounce time.Duration
+
+	//The states of the watched paths
+	states map[string]*watchState
+
+	//Closed to stop watching
+	stop chan struct{}
+
+	//Closed when the watching is stopped
+	done chan struct{}
+}
+
+//StartWatcher implements the interface method
+func (bm *BaseManager) StartWatcher(interval time.Duration, debounce time.Duration) error {
+	baseDir := bm.pluginBaseDir()
+	if len(baseDir) == 0 {
+		return errors.New("plugin base dir is not set")
+	}
+
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	if debounce <= 0 {
+		debounce = DefaultWatchDebounce
+	}
+
+	bm.lock.Lock()
+	defer bm.lock.Unlock()
+
+	if bm.watcher != nil {
+		return errors.New("watcher is already started")
+	}
+
+	w := &watcher{
+		manager:  bm,
+		interval: interval,
+		debounce: debounce,
+		states:   make(map[string]*watchState),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	//The existing ones are treated as applied
+	paths, err := bm.loader.Scan(baseDir)
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		fp := fingerprint(p)
+		w.states[p] = &watchState{fingerprint: fp, applied: fp}
+	}
+
+	bm.watcher = w
+	go w.run()
+
+	return nil
+}
+
+//StopWatcher implements the interface method
+func (bm *BaseManager) StopWatcher() {
+	bm.lock.Lock()
+	w := bm.watcher
+	bm.watcher = nil
+	bm.lock.Unlock()
+
+	if w != nil {
+		close(w.stop)
+		<-w.done
+	}
+}
+
+//SubscribeWatchEvents implements the interface method
+func (bm *BaseManager) SubscribeWatchEvents() (<-chan *WatchEvent, func()) {
+	return bm.watchEvents.subscribe(watchEventBuffer, DropNewest)
+}
+
+func (w *watcher) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.poll()
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+//poll the plugin base dir and apply the changes which are stable for the debounce period
+func (w *watcher) poll() {
+	baseDir := w.manager.pluginBaseDir()
+	paths, err := w.manager.loader.Scan(baseDir)
+	if err != nil {
+		w.manager.logger.Error("Watch plugin base dir error", "dir", baseDir, "error", err)
+		return
+	}
+
+	now := time.Now()
+	seen := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		seen[p] = true
+		fp := fingerprint(p)
+
+		state, ok := w.states[p]
+		if !ok {
+			state = &watchState{}
+			w.states[p] = state
+		}
+		if state.removed || state.fingerprint != fp {
+			state.fingerprint, state.removed, state.changedAt = fp, false, now
+		}
+	}
+
+	for p, state := range w.states {
+		if !seen[p] && !state.removed {
+			state.removed, state.changedAt = true, now
+		}
+	}
+
+	for p, state := range w.states {
+		if now.Sub(state.changedAt) < w.debounce {
+			//Wait for the partial copies
+			continue
+		}
+
+		switch {
+		case state.removed:
+			delete(w.states, p)
+			if len(state.applied) > 0 {
+				w.unload(p)
+			}
+		case state.fingerprint != state.applied:
+			state.applied = state.fingerprint
+			w.apply(p)
+		}
+	}
+}
+
+//apply the new or changed plugin dir or bundle
+func (w *watcher) apply(pluginPath string) {
+	bm := w.manager
+
+	existing := loadedFrom(bm.store, pluginPath)
+	if len(existing) == 0 {
+		bm.emitPath(EventDiscovered, pluginPath, nil)
+	}
+
+	action := WatchActionLoad
+	if len(existing) > 0 {
+		action = WatchActionUpgrade
+	}
+
+	//The validation failure is sent as the plugin event by the validation
+	pluginSpec, err := bm.validatePlugin(pluginPath)
+	if err != nil {
+		name, _ := pkg.SplitPluginDirName(filepath.Base(pluginPath))
+		w.publish(action, pluginPath, name, "", err)
+		return
+	}
+
+	if len(existing) == 0 {
+		err := checkDependencies(bm.store, pluginSpec)
+		if err != nil {
+			bm.emit(EventLoadFailed, pluginSpec, err)
+		} else {
+			err = bm.load(pluginSpec)
+		}
+		w.publish(WatchActionLoad, pluginPath, pluginSpec.Name, pluginSpec.Version, err)
+		return
+	}
+
+	//Only one version is loaded from the same path. Unlike 'UpgradePlugin', the version
+	//is not required to be newer as the path is the source of the loaded one, the change
+	//without a newer version (e.g: rebuilt in place) is reported as the reload action.
+	if !isNewer(pluginSpec.Version, existing[0].Spec.Version) {
+		action = WatchActionReload
+	}
+	if !bm.beginUpgrade(pluginSpec.Name) {
+		w.publish(action, pluginPath, pluginSpec.Name, pluginSpec.Version, fmt.Errorf("plugin %s is being upgraded", pluginSpec.Name))
+		return
+	}
+	err = bm.upgrade(existing[0], pluginSpec)
+	bm.endUpgrade(pluginSpec.Name)
+	w.publish(action, pluginPath, pluginSpec.Name, pluginSpec.Version, err)
+}
+
+//isNewer checks if the version is newer than the other one, the invalid semantic
+//versions are not newer
+func isNewer(version string, than string) bool {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return false
+	}
+	t, err := semver.NewVersion(than)
+	if err != nil {
+		return false
+	}
+
+	return v.GreaterThan(t)
+}
+
+//unload the plugins loaded from the removed plugin dir or bundle
+func (w *watcher) unload(pluginPath string) {
+	for _, item := range loadedFrom(w.manager.store, pluginPath) {
+		err := w.manager.UnloadPluginVersion(item.Spec.Name, item.Spec.Version)
+		w.publish(WatchActionUnload, pluginPath, item.Spec.Name, item.Spec.Version, err)
+	}
+}
+
+func (w *watcher) publish(action string, pluginPath string, name string, version string, err error) {
+	if err != nil {
+		w.manager.logger.Error("Watcher action error", "action", action, "path", pluginPath, "error", err)
+	} else {
+		w.manager.logger.Info("Watcher action [SUCCESS]", "action", action, "plugin", name, "version", version)
+	}
+
+	dropped := w.manager.watchEvents.publish(&WatchEvent{
+		Action:  action,
+		Path:    pluginPath,
+		Name:    name,
+		Version: version,
+		Time:    time.Now(),
+		Err:     err,
+	})
+	if dropped > 0 {
+		w.manager.logger.Warn("Watch event is dropped by slow subscribers", "path", pluginPath, "subscribers", dropped)
+	}
+}
+
+//loadedFrom returns the loaded plugin items which are loaded from the path
+func loadedFrom(store Store, pluginPath string) []*spec.PluginItem {
+	items := make([]*spec.PluginItem, 0)
+	for _, item := range store.List() {
+		if item.Spec.Path == pluginPath {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+//fingerprint computes the fingerprint of the plugin dir or bundle
+//with the names, sizes, modes and modification times of the files.
+func fingerprint(pluginPath string) string {
+	h := fnv.New64a()
+	filepath.Walk(pluginPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			fmt.Fprintf(h, "%s:%s\n", path, err)
+			return nil
+		}
+
+		fmt.Fprintf(h, "%s:%d:%s:%d\n", path, info.Size(), info.Mode(), info.ModTime().UnixNano())
+		return nil
+	})
+
+	return fmt.Sprintf("%x", h.Sum64())
+}
@@ -0,0 +1,225 @@
+package plugin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFingerprint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fingerprint-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "plugin.json")
+	if err := ioutil.WriteFile(file, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(file, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	original := fingerprint(dir)
+
+	cases := []struct {
+		name    string
+		change  func() error
+		changed bool
+	}{
+		{"untouched", func() error { return nil }, false},
+		{"content size", func() error { return ioutil.WriteFile(file, []byte("{ }"), 0644) }, true},
+		{"modification time", func() error { return os.Chtimes(file, modTime, modTime.Add(time.Second)) }, true},
+		{"mode", func() error { return os.Chmod(file, 0600) }, true},
+		{"new file", func() error { return ioutil.WriteFile(filepath.Join(dir, "main.go"), nil, 0644) }, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			//Restore the original state
+			os.Remove(filepath.Join(dir, "main.go"))
+			if err := ioutil.WriteFile(file, []byte("{}"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chmod(file, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(file, modTime, modTime); err != nil {
+				t.Fatal(err)
+			}
+			if fp := fingerprint(dir); fp != original {
+				t.Fatalf("expect fingerprint %s of the original state but got %s", original, fp)
+			}
+
+			if err := c.change(); err != nil {
+				t.Fatal(err)
+			}
+			if changed := fingerprint(dir) != original; changed != c.changed {
+				t.Fatalf("expect fingerprint changed %v but got %v", c.changed, changed)
+			}
+		})
+	}
+}
+
+func TestWatcherPoll(t *testing.T) {
+	baseDir, err := ioutil.TempDir("", "watcher-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(baseDir)
+
+	bm := loadPlugins(t, baseDir)
+	bm.SetUpgradeGracePeriod(0)
+	events, cancel := bm.SubscribeWatchEvents()
+	defer cancel()
+	pluginEvents, cancelPluginEvents := bm.Subscribe(0, DropNewest)
+	defer cancelPluginEvents()
+
+	//Not started, polled by the test
+	w := &watcher{
+		manager:  bm,
+		debounce: time.Hour,
+		states:   make(map[string]*watchState),
+	}
+	//expire moves the changes back so the debounce period is elapsed
+	expire := func() {
+		for _, state := range w.states {
+			state.changedAt = state.changedAt.Add(-w.debounce)
+		}
+	}
+
+	//The dir without version in the name is able to change the version
+	dir := filepath.Join(baseDir, "billing")
+	source := pluginSource(false, "", filepath.Join(baseDir, "shutdown"))
+	write := func(version string, source string) {
+		staged := writePlugin(t, t.TempDir(), "billing", version, "", source)
+		for _, f := range []string{"plugin.json", "main.go"} {
+			content, err := ioutil.ReadFile(filepath.Join(staged, f))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(filepath.Join(dir, f), content, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	write("1.0.0", source)
+
+	cases := []struct {
+		name    string
+		change  func()
+		expired bool
+		action  string
+		version string
+		failed  bool
+	}{
+		{
+			name:   "new dir in the debounce period",
+			change: func() {},
+		},
+		{
+			name: "changed again in the debounce period",
+			change: func() {
+				expire()
+				write("1.0.0", source+"\n")
+			},
+		},
+		{
+			name:    "new dir",
+			change:  func() {},
+			expired: true,
+			action:  WatchActionLoad,
+			version: "1.0.0",
+		},
+		{
+			name:    "unchanged",
+			change:  func() {},
+			expired: true,
+		},
+		{
+			name:    "rebuilt with the same version",
+			change:  func() { write("1.0.0", source) },
+			expired: true,
+			action:  WatchActionReload,
+			version: "1.0.0",
+		},
+		{
+			name:    "newer version",
+			change:  func() { write("2.0.0", source) },
+			expired: true,
+			action:  WatchActionUpgrade,
+			version: "2.0.0",
+		},
+		{
+			name: "invalid",
+			change: func() {
+				if err := ioutil.WriteFile(filepath.Join(dir, "plugin.json"), []byte("{"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			expired: true,
+			action:  WatchActionUpgrade,
+			failed:  true,
+		},
+		{
+			name:    "lower version",
+			change:  func() { write("1.5.0", source) },
+			expired: true,
+			action:  WatchActionReload,
+			version: "1.5.0",
+		},
+		{
+			name:    "removed",
+			change:  func() { os.RemoveAll(dir) },
+			expired: true,
+			action:  WatchActionUnload,
+			version: "1.5.0",
+		},
+	}
+
+	for _, c := range cases {
+		//The cases depend on the previous ones
+		c.change()
+		w.poll()
+		if c.expired {
+			expire()
+			w.poll()
+		}
+
+		select {
+		case e := <-events:
+			if len(c.action) == 0 {
+				t.Fatalf("%s: expect no action but got %s %s", c.name, e.Action, e.Err)
+			}
+			if e.Action != c.action || e.Version != c.version || (e.Err != nil) != c.failed {
+				t.Fatalf("%s: expect action %s of version %q failed %v but got %s of %q with %v", c.name, c.action, c.version, c.failed, e.Action, e.Version, e.Err)
+			}
+		default:
+			if len(c.action) > 0 {
+				t.Fatalf("%s: expect action %s but got nothing", c.name, c.action)
+			}
+		}
+	}
+
+	//The failed validation is not reported as the load failure
+	drained := false
+	for !drained {
+		select {
+		case e := <-pluginEvents:
+			if e.Type == EventLoadFailed {
+				t.Fatalf("expect no %s event but got the one of %s", EventLoadFailed, e.Path)
+			}
+		default:
+			drained = true
+		}
+	}
+	if _, _, err := bm.GetPlugin("billing"); err == nil {
+		t.Fatal("expect the plugin of the removed dir unloaded")
+	}
+}
@@ -0,0 +1,97 @@
+package process
+
+import (
+	"encoding/json"
+	"time"
+)
+
+//The methods supported by the plugin process
+const (
+	//MethodInit calls the 'Init' handler
+	MethodInit = "init"
+
+	//MethodShutdown calls the 'Shutdown' handler
+	MethodShutdown = "shutdown"
+
+	//MethodHealth calls the 'Health' handler
+	MethodHealth = "health"
+
+	//MethodExecute calls the entry handler
+	MethodExecute = "execute"
+
+	//MethodCancel cancels the running request with the same ID
+	MethodCancel = "cancel"
+)
+
+//DefaultEntry is the name of the default entry handler
+const DefaultEntry = "Execute"
+
+//Request is sent from the host to the plugin process as one JSON line
+type Request struct {
+	//ID of the request, the response has the same ID
+	ID uint64 `json:"id"`
+
+	//The method to call
+	Method string `json:"method"`
+
+	//The name of the entry to execute, only for 'execute' method
+	Entry string `json:"entry,omitempty"`
+
+	//The JSON encoded plugin context values
+	Values map[string]json.RawMessage `json:"values,omitempty"`
+
+	//The deadline of the plugin context
+	Deadline *time.Time `json:"deadline,omitempty"`
+}
+
+//Response is sent from the plugin process to the host as one JSON line
+type Response struct {
+	//ID of the request
+	ID uint64 `json:"id"`
+
+	//The JSON encoded values set to the plugin context by the plugin
+	Values map[string]json.RawMessage `json:"values,omitempty"`
+
+	//The error message returned by the plugin
+	Error string `json:"error,omitempty"`
+
+	//Whether the error is marked as non-retryable by the plugin
+	NonRetryable bool `json:"non_retryable,omitempty"`
+
+	//Whether the plugin panicked in the call, the error is the recovered value
+	Crashed bool `json:"crashed,omitempty"`
+}
+
+//EncodeValues encodes the plugin context values with JSON.
+//The values which can not be encoded are skipped.
+func EncodeValues(values map[string]interface{}) map[string]json.RawMessage {
+	if len(values) == 0 {
+		return nil
+	}
+
+	encoded := make(map[string]json.RawMessage, len(values))
+	for k, v := range values {
+		data, err := json.Marshal(v)
+		if err != nil {
+			continue
+		}
+		encoded[k] = data
+	}
+
+	return encoded
+}
+
+//DecodeValues decodes the JSON encoded plugin context values.
+//The values are decoded as the generic JSON types, e.g: 'map[string]interface{}' for objects.
+func DecodeValues(encoded map[string]json.RawMessage) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(encoded))
+	for k, data := range encoded {
+		var v interface{}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		values[k] = v
+	}
+
+	return values, nil
+}
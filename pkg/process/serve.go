@@ -0,0 +1,305 @@
+package process
+
+import (
+	sys_context "context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/steven-zou/go-plugin/pkg/context"
+	"github.com/steven-zou/go-plugin/pkg/logger"
+	"github.com/steven-zou/go-plugin/pkg/spec"
+)
+
+//Handlers are the functions served by the plugin process
+type Handlers struct {
+	//The default entry
+	Execute spec.PluginExecutor
+
+	//The named entries, the names are the ones declared in the 'entries' of 'plugin.json'
+	Entries map[string]spec.PluginExecutor
+
+	//Optional lifecycle functions
+	Init     spec.LifecycleFunc
+	Shutdown spec.LifecycleFunc
+	Health   spec.LifecycleFunc
+
+	//Optional logger of the serving errors, written to the stderr if not set
+	Logger logger.Logger
+}
+
+//Serve the handlers over the stdin and stdout until the stdin is closed by the host.
+//It should be called in the main function of the plugin executable.
+//The os.Stdout is redirected to os.Stderr as the stdout is used by the protocol.
+func Serve(handlers *Handlers) error {
+	if handlers == nil {
+		return fmt.Errorf("nil handlers")
+	}
+
+	out := os.Stdout
+	os.Stdout = os.Stderr
+
+	return newServer(handlers, out).serve(os.Stdin)
+}
+
+//server handles the requests from the host
+type server struct {
+	//The served handlers
+	handlers *Handlers
+
+	//internal lock
+	lock *sync.Mutex
+
+	//Encode the responses
+	encoder *json.Encoder
+
+	//The cancel functions of the running requests
+	running map[uint64]sys_context.CancelFunc
+
+	//Log the serving errors
+	logger logger.Logger
+}
+
+func newServer(handlers *Handlers, out io.Writer) *server {
+	l := handlers.Logger
+	if l == nil {
+		l = logger.Default()
+	}
+
+	return &server{
+		handlers: handlers,
+		lock:     new(sync.Mutex),
+		encoder:  json.NewEncoder(out),
+		running:  make(map[uint64]sys_context.CancelFunc),
+		logger:   l,
+	}
+}
+
+func (s *server) serve(in io.Reader) error {
+	wg := new(sync.WaitGroup)
+	defer wg.Wait()
+
+	decoder := json.NewDecoder(in)
+	for {
+		req := &Request{}
+		if err := decoder.Decode(req); err != nil {
+			//Host is gone, cancel all the running ones
+			s.lock.Lock()
+			for _, cancel := range s.running {
+				cancel()
+			}
+			s.lock.Unlock()
+
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		if req.Method == MethodCancel {
+			s.lock.Lock()
+			if cancel, ok := s.running[req.ID]; ok {
+				cancel()
+			}
+			s.lock.Unlock()
+			continue
+		}
+
+		//Register before dispatching, so the cancel request following it is not missed
+		ctx, cancel := s.register(req)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.handle(req, ctx, cancel)
+		}()
+	}
+}
+
+//register the request as a running one with its context and cancel function
+func (s *server) register(req *Request) (sys_context.Context, sys_context.CancelFunc) {
+	var (
+		ctx    sys_context.Context
+		cancel sys_context.CancelFunc
+	)
+	if req.Deadline != nil {
+		ctx, cancel = sys_context.WithDeadline(sys_context.Background(), *req.Deadline)
+	} else {
+		ctx, cancel = sys_context.WithCancel(sys_context.Background())
+	}
+
+	s.lock.Lock()
+	s.running[req.ID] = cancel
+	s.lock.Unlock()
+
+	return ctx, cancel
+}
+
+func (s *server) handle(req *Request, ctx sys_context.Context, cancel sys_context.CancelFunc) {
+	defer func() {
+		s.lock.Lock()
+		delete(s.running, req.ID)
+		s.lock.Unlock()
+		cancel()
+	}()
+
+	resp := &Response{ID: req.ID}
+	input, err := DecodeValues(req.Values)
+	if err != nil {
+		resp.Error = err.Error()
+		s.reply(resp)
+		return
+	}
+
+	pCtx := &requestContext{
+		Context: ctx,
+		input:   input,
+		output:  make(map[string]interface{}),
+		lock:    new(sync.RWMutex),
+	}
+	if err := s.call(req, pCtx); err != nil {
+		var pe *panicError
+		resp.Error = err.Error()
+		resp.NonRetryable = context.IsNonRetryable(err)
+		resp.Crashed = errors.As(err, &pe)
+	}
+	resp.Values = EncodeValues(pCtx.changes())
+
+	s.reply(resp)
+}
+
+//panicError is the panic recovered from the handler
+type panicError struct {
+	//The value passed to panic
+	value interface{}
+}
+
+//Error implements the error interface
+func (pe *panicError) Error() string {
+	return fmt.Sprintf("plugin panic: %v", pe.value)
+}
+
+func (s *server) call(req *Request, ctx context.PluginContext) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &panicError{value: r}
+		}
+	}()
+
+	var fn func(ctx context.PluginContext) error
+	switch req.Method {
+	case MethodInit:
+		fn = s.handlers.Init
+	case MethodShutdown:
+		fn = s.handlers.Shutdown
+	case MethodHealth:
+		fn = s.handlers.Health
+	case MethodExecute:
+		if len(req.Entry) == 0 || req.Entry == DefaultEntry {
+			fn = s.handlers.Execute
+		} else {
+			fn = s.handlers.Entries[req.Entry]
+		}
+		if fn == nil {
+			return fmt.Errorf("entry '%s' is not served", req.Entry)
+		}
+	default:
+		return fmt.Errorf("unknown method '%s'", req.Method)
+	}
+
+	//The lifecycle functions are optional
+	if fn == nil {
+		return nil
+	}
+
+	return fn(ctx)
+}
+
+func (s *server) reply(resp *Response) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.encoder.Encode(resp); err != nil {
+		s.logger.Error("Failed to reply request", "id", resp.ID, "error", err)
+	}
+}
+
+//requestContext is the plugin context of the request in the plugin process,
+//it records the values set by the plugin to send them back to the host.
+type requestContext struct {
+	sys_context.Context
+
+	//The values from the host
+	input map[string]interface{}
+
+	//The values set by the plugin
+	output map[string]interface{}
+
+	//internal lock
+	lock *sync.RWMutex
+}
+
+//GetValue implements 'GetValue' in ValueContext interface
+func (rc *requestContext) GetValue(key string) interface{} {
+	rc.lock.RLock()
+	defer rc.lock.RUnlock()
+
+	if v, ok := rc.output[key]; ok {
+		return v
+	}
+
+	return rc.input[key]
+}
+
+//SetValue implements 'SetValue' in ValueContext interface
+func (rc *requestContext) SetValue(key string, value interface{}) {
+	if len(strings.TrimSpace(key)) == 0 {
+		return
+	}
+
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+
+	rc.output[key] = value
+}
+
+//Values implements 'Values' in ValueLister interface
+func (rc *requestContext) Values() map[string]interface{} {
+	rc.lock.RLock()
+	defer rc.lock.RUnlock()
+
+	values := make(map[string]interface{}, len(rc.input)+len(rc.output))
+	for k, v := range rc.input {
+		values[k] = v
+	}
+	for k, v := range rc.output {
+		values[k] = v
+	}
+
+	return values
+}
+
+//DeleteValue implements 'DeleteValue' in ValueDeleter interface.
+//Only the value set by the plugin is deleted.
+func (rc *requestContext) DeleteValue(key string) {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+
+	delete(rc.output, key)
+}
+
+func (rc *requestContext) changes() map[string]interface{} {
+	rc.lock.RLock()
+	defer rc.lock.RUnlock()
+
+	changes := make(map[string]interface{}, len(rc.output))
+	for k, v := range rc.output {
+		changes[k] = v
+	}
+
+	return changes
+}
@@ -4,3 +4,7 @@ import "github.com/steven-zou/go-plugin/pkg/context"
 
 //PluginExecutor is the executor of the plugin
 type PluginExecutor func(ctx context.PluginContext) error
+
+//LifecycleFunc is the optional lifecycle function of the plugin,
+//including 'Init', 'Shutdown' and 'Health'
+type LifecycleFunc func(ctx context.PluginContext) error
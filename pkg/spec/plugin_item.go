@@ -1,5 +1,7 @@
 package spec
 
+import sys_plugin "plugin"
+
 //PluginItem is composite of plugin spec and executor
 type PluginItem struct {
 	//Plugin spec
@@ -7,4 +9,19 @@ type PluginItem struct {
 
 	//Plugin executor
 	Executor PluginExecutor
+
+	//The named entry executors with labels
+	Entries map[string]PluginExecutor
+
+	//Optional lifecycle function called after loading
+	Init LifecycleFunc
+
+	//Optional lifecycle function called when unloading
+	Shutdown LifecycleFunc
+
+	//Optional lifecycle function to check the health
+	Health LifecycleFunc
+
+	//The handle of the opened plugin so file for looking up other symbols
+	Handle *sys_plugin.Plugin
 }
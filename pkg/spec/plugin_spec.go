@@ -23,16 +23,121 @@ type Plugin struct {
 
 	//The HTTP service should be served by the plugin
 	HTTPServices *HTTPServices
+
+	//The named entry points of the plugin, optional.
+	//Map the label to the name of the exported entry function.
+	Entries map[string]string
+
+	//The label of the entry used as the default executor, optional.
+	//If no entries declared, the 'Execute' function is the default one.
+	//If entries declared but no default entry, the entry is chosen by
+	//the 'label' value in the plugin context.
+	DefaultEntry string `json:"default_entry"`
+
+	//The sandbox settings of the 'wasm' mode, optional
+	Wasm *Wasm
+
+	//The default timeout of executing the plugin with the manager,
+	//in the Go duration format, e.g: '500ms' or '30s', optional
+	Timeout string
+
+	//The limits of the concurrent executions of the plugin, optional.
+	//If not set, the executions are not limited.
+	Concurrency *Concurrency
+
+	//The circuit breaker of the executions of the plugin, optional.
+	//If not set, the executions are not broken.
+	CircuitBreaker *CircuitBreaker `json:"circuit_breaker"`
+
+	//The retry policy of the failed executions of the plugin, optional.
+	//If not set, the executions are not retried.
+	Retry *Retry
+
+	//The plugins should be loaded and initialized before this one, optional.
+	//Each dependency declares the plugin name and the semver constraint.
+	Dependencies []*Requirement
+
+	//The path of the plugin dir or bundle where the plugin is loaded from,
+	//it's set by the manager
+	Path string `json:"-"`
 }
 
 //Source defines the loading mode of the plugin
 type Source struct {
 	//The loading mode of the plugin
-	//Support 'local_so', 'remote_git'
+	//Support 'local_so', 'local_src', 'remote_git', 'process', 'interpreted', 'wasm'
 	Mode string
 
-	//The path of the local so file or the URL of the remote git
+	//The path of the local so file, the path of the local source package dir
+	//(for 'local_src' and 'interpreted'), the URL of the remote git, the path
+	//of the plugin executable or the path of the wasm module
 	Path string
+
+	//The git ref (branch, tag or commit) to check out,
+	//required by the 'remote_git' mode
+	Ref string
+}
+
+//Wasm defines the sandbox settings of the plugin loaded with 'wasm' mode
+type Wasm struct {
+	//The max memory of the module instance in MiB, optional.
+	//If not set, the 'DefaultWasmMemoryLimit' is used.
+	MemoryLimit uint32 `json:"memory_limit"`
+}
+
+//Concurrency defines the limits of the concurrent executions of the plugin
+type Concurrency struct {
+	//The max executions running at the same time, required
+	MaxInFlight int `json:"max_in_flight"`
+
+	//The max executions waiting for a free slot, optional.
+	//If not set, the executions over the limit are rejected at once.
+	QueueLength int `json:"queue_length"`
+
+	//The max time an execution waits in the queue, in the Go duration
+	//format, optional. If not set, it waits until the context is done.
+	QueueTimeout string `json:"queue_timeout"`
+}
+
+//CircuitBreaker defines when to stop executing the failing plugin
+type CircuitBreaker struct {
+	//The error rate (0 to 1] of the executions in the window to open the circuit, required
+	ErrorRate float64 `json:"error_rate"`
+
+	//The min executions in the window before the error rate is checked, optional
+	MinRequests int `json:"min_requests"`
+
+	//The sliding window of counting the executions, in the Go duration format, optional
+	Window string
+
+	//How long the circuit keeps open before trying the executions again,
+	//in the Go duration format, optional
+	OpenDuration string `json:"open_duration"`
+
+	//The successful trial executions in the half-open state to close the circuit, optional
+	HalfOpenRequests int `json:"half_open_requests"`
+}
+
+//Retry defines how to retry the failed executions of the plugin
+type Retry struct {
+	//The max attempts of the execution including the first one, required
+	MaxAttempts int `json:"max_attempts"`
+
+	//The backoff before the first retry, in the Go duration format, optional
+	InitialBackoff string `json:"initial_backoff"`
+
+	//The max backoff between the retries, in the Go duration format, optional
+	MaxBackoff string `json:"max_backoff"`
+
+	//The factor to grow the backoff after each retry, optional
+	Multiplier float64
+
+	//The fraction [0, 1] of the backoff to randomize, optional
+	Jitter *float64
+
+	//The classes of the retryable errors: 'error', 'timeout', 'overloaded'
+	//and 'crash', optional
+	RetryOn []string `json:"retry_on"`
 }
 
 //HTTPServiceRoute defines the http/rest service endpoint served by the plugin
@@ -0,0 +1,18 @@
+package spec
+
+//Requirement declares a plugin with the semver constraint of its versions
+type Requirement struct {
+	//Name of the plugin, required
+	Name string
+
+	//The semver constraint of the versions, e.g: '^1.4, <2.0', optional.
+	//If not set, any version is matched.
+	Version string
+}
+
+//Requirements is the corresponding structure of the requirements json file,
+//which declares the plugins required by the host
+type Requirements struct {
+	//The required plugins
+	Plugins []*Requirement
+}
@@ -0,0 +1,16 @@
+//go:build go1.21
+// +build go1.21
+
+package symbols
+
+import (
+	"github.com/steven-zou/go-plugin/pkg/logger"
+	"reflect"
+)
+
+//The logger symbols only available with Go 1.21 or later, they are added to the
+//generated ones which are initialized first as the files are sorted by name.
+func init() {
+	Symbols["github.com/steven-zou/go-plugin/pkg/logger/logger"]["NewSlogLogger"] = reflect.ValueOf(logger.NewSlogLogger)
+	Symbols["github.com/steven-zou/go-plugin/pkg/logger/logger"]["SlogLogger"] = reflect.ValueOf((*logger.SlogLogger)(nil))
+}
@@ -0,0 +1,14 @@
+//Package symbols exports the symbols of the go-plugin packages to the
+//interpreter which runs the interpreted plugins.
+package symbols
+
+import "reflect"
+
+//go:generate yaegi extract -name symbols github.com/steven-zou/go-plugin/pkg/context
+//go:generate yaegi extract -name symbols github.com/steven-zou/go-plugin/pkg/logger
+
+//The 'log/slog' based symbols of the logger package are kept in the Go 1.21 tagged file,
+//move them out of the generated file after regenerating.
+
+//Symbols variable stores the map of the exported symbols per package
+var Symbols = map[string]map[string]reflect.Value{}
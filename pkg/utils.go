@@ -2,17 +2,39 @@ package pkg
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 const (
 	//PluginJSONFileName is the pre-defined filename of plugin metadata json file
 	PluginJSONFileName = "plugin.json"
 
+	//PluginBundleExt is the file extension of the plugin bundle,
+	//which is a gzip compressed tarball of the plugin dir
+	PluginBundleExt = ".plg"
+
+	//PluginVersionSeparator separates the plugin name and the version
+	//in the plugin dir or bundle name, e.g: 'billing@1.2.0'
+	PluginVersionSeparator = "@"
+
 	//PluginSourceModeLocal defines the local mode
 	PluginSourceModeLocal = "local_so"
 
 	//PluginSourceModeRemote defines the remote mode
 	PluginSourceModeRemote = "remote_git"
+
+	//PluginSourceModeLocalSrc defines the local source mode
+	PluginSourceModeLocalSrc = "local_src"
+
+	//PluginSourceModeProcess defines the out-of-process mode
+	PluginSourceModeProcess = "process"
+
+	//PluginSourceModeInterpreted defines the interpreted source mode
+	PluginSourceModeInterpreted = "interpreted"
+
+	//PluginSourceModeWasm defines the WebAssembly mode
+	PluginSourceModeWasm = "wasm"
 )
 
 //FileExists check the existence of the specified file
@@ -22,9 +44,28 @@ func FileExists(filePath string) bool {
 	return err == nil
 }
 
+//IsBundle checks if the file is a plugin bundle file
+func IsBundle(filePath string) bool {
+	fi, err := os.Stat(filePath)
+
+	return err == nil && fi.Mode().IsRegular() && filepath.Ext(filePath) == PluginBundleExt
+}
+
 //IsDir checks if the file is a dir
 func IsDir(filePath string) bool {
 	fi, err := os.Stat(filePath)
 
 	return err == nil && fi.Mode().IsDir()
 }
+
+//SplitPluginDirName splits the plugin dir or bundle name with the 'name@version'
+//layout into the plugin name and the version. If no version is included, the
+//version is empty.
+func SplitPluginDirName(dirName string) (string, string) {
+	dirName = strings.TrimSuffix(dirName, PluginBundleExt)
+	if i := strings.LastIndex(dirName, PluginVersionSeparator); i >= 0 {
+		return dirName[:i], dirName[i+len(PluginVersionSeparator):]
+	}
+
+	return dirName, ""
+}